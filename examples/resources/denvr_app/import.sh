@@ -0,0 +1,3 @@
+# Applications are imported by "<cluster>/<name>". The image of a custom application
+# isn't returned by the API, so it's taken from the configuration after import.
+terraform import denvr_app.terraform_app Msc1/terraform-app
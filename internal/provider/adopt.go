@@ -0,0 +1,238 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/denvrdata/go-denvr/api/v1/servers/applications"
+	"github.com/denvrdata/go-denvr/api/v1/servers/virtual"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// denvrServersPath lists the tenant's virtual machines in a cluster.
+const denvrServersPath = "/api/v1/servers/virtual/GetServers"
+
+// adoptExistingAttribute is the adopt_existing attribute, which lets Create pick up a resource
+// left behind by an interrupted apply instead of failing on its name being taken. limits describes
+// what the Denvr API doesn't return about the kind of resource, so can't be checked.
+func adoptExistingAttribute(kind, limits string) schema.BoolAttribute {
+	return schema.BoolAttribute{
+		Optional: true,
+		Computed: true,
+		Default:  booldefault.StaticBool(false),
+		MarkdownDescription: fmt.Sprintf("Take an existing %s with the same name and cluster into state instead of creating a new one, "+
+			"e.g. after an apply was interrupted once the %s had been created. Creating fails if the existing %s doesn't match "+
+			"the planned one, or if the Denvr API doesn't return enough of it to tell. %s Defaults to `false`.", kind, kind, kind, limits),
+	}
+}
+
+// existingServer is a virtual machine as listed by denvrServersPath.
+type existingServer struct {
+	Id            string `json:"id"`
+	Namespace     string `json:"namespace"`
+	Cluster       string `json:"cluster"`
+	Configuration string `json:"configuration"`
+	Rpool         string `json:"rpool"`
+	Vpc           string `json:"vpc"`
+}
+
+// findServer returns the tenant's virtual machine with the given name in a cluster, or nil if there isn't one.
+func (c *denvrClient) findServer(ctx context.Context, cluster, name string) (*existingServer, error) {
+	result, err := c.getResult(ctx, denvrServersPath, url.Values{"cluster": {cluster}})
+	if err != nil {
+		return nil, err
+	}
+	servers, err := decodeItems[existingServer](result)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse servers response: %w", err)
+	}
+
+	// VMs are identified by their name
+	for _, server := range servers {
+		if server.Id == name && (server.Cluster == "" || server.Cluster == cluster) {
+			server.Cluster = cluster
+			return &server, nil
+		}
+	}
+	return nil, nil
+}
+
+// matchPlacement returns the planned placement the existing VM is in, if any.
+func (s existingServer) matchPlacement(placements []vmPlacementModel) (vmPlacementModel, bool) {
+	for _, p := range placements {
+		if p.Cluster.ValueString() == s.Cluster && p.Configuration.ValueString() == s.Configuration && p.Rpool.ValueString() == s.Rpool {
+			return p, true
+		}
+	}
+	return vmPlacementModel{}, false
+}
+
+// adoptServer looks up an existing VM with the planned name in each of the planned clusters. If it
+// matches one of the placements and the rest of the plan it's returned, and the placement is recorded
+// in data. It returns nil if there's no such VM, and adds an error if there's one which doesn't match
+// or can't be checked.
+func (r *vmResource) adoptServer(ctx context.Context, client virtual.Client, data *vmResourceModel, placements []vmPlacementModel, diags *diag.Diagnostics) *virtual.Server {
+	name := data.Name.ValueString()
+	looked := map[string]bool{}
+	for _, p := range placements {
+		cluster := p.Cluster.ValueString()
+		if looked[cluster] {
+			continue
+		}
+		looked[cluster] = true
+
+		existing, err := r.client.findServer(ctx, cluster, name)
+		if err != nil {
+			diags.AddError(
+				"Unable to Look Up Existing VM",
+				fmt.Sprintf("adopt_existing is set, but the VMs in cluster %s could not be listed: %s", cluster, err),
+			)
+			return nil
+		}
+		if existing == nil {
+			continue
+		}
+
+		placement, ok := existing.matchPlacement(placements)
+		if !ok {
+			addServerMismatchError(name, cluster, []string{
+				fmt.Sprintf("its configuration %s in resource pool %s isn't one of the planned placements", existing.Configuration, existing.Rpool),
+			}, diags)
+			return nil
+		}
+
+		server, err := client.GetServer(ctx, &virtual.GetServerParams{
+			Id:        existing.Id,
+			Namespace: existing.Namespace,
+			Cluster:   existing.Cluster,
+		})
+		if err != nil {
+			diags.AddError("Error getting server", err.Error())
+			return nil
+		}
+		if mismatches := serverMismatches(*data, *existing, *server); len(mismatches) > 0 {
+			addServerMismatchError(name, cluster, mismatches, diags)
+			return nil
+		}
+
+		// GetServer only returns the total storage of the VM, which includes its other disks
+		diags.AddAttributeWarning(
+			path.Root("root_disk_size"),
+			"Root Disk Size Not Checked",
+			fmt.Sprintf("The existing VM %q in cluster %s was adopted without checking its root disk size, which isn't returned by the Denvr API. "+
+				"If it isn't %d GB, replace the VM to change it.", name, cluster, data.RootDiskSize.ValueInt32()),
+		)
+
+		tflog.Info(ctx, fmt.Sprintf("Adopting existing VM %s/%s/%s", existing.Cluster, existing.Namespace, existing.Id))
+		data.Cluster, data.Configuration, data.Rpool = placement.Cluster, placement.Configuration, placement.Rpool
+		return server
+	}
+	return nil
+}
+
+func addServerMismatchError(name, cluster string, mismatches []string, diags *diag.Diagnostics) {
+	diags.AddAttributeError(
+		path.Root("adopt_existing"),
+		"Existing VM Does Not Match",
+		fmt.Sprintf("A VM named %q already exists in cluster %s, but it was not adopted because %s. ", name, cluster, strings.Join(mismatches, " and "))+
+			"VMs can't be imported, so destroy the existing VM or choose another name.",
+	)
+}
+
+// serverMismatches describes how an existing VM differs from the planned one, given its entry in the
+// server list and the server returned by GetServer. An attribute the API doesn't return counts as a
+// mismatch, so a VM is only adopted once everything which identifies it has been checked. The root
+// disk size isn't returned at all, so adoptServer warns about it instead.
+func serverMismatches(data vmResourceModel, existing existingServer, server virtual.Server) []string {
+	var mismatches []string
+	mismatch := func(attr, planned string, actual *string) {
+		if actual == nil || *actual == "" {
+			mismatches = append(mismatches, fmt.Sprintf("its %s isn't returned by the Denvr API, so it can't be checked", attr))
+		} else if *actual != planned {
+			mismatches = append(mismatches, fmt.Sprintf("its %s is %q, not %q", attr, *actual, planned))
+		}
+	}
+
+	mismatch("operating_system_image", data.OperatingSystemImage.ValueString(), server.Image)
+	mismatch("vpc", data.Vpc.ValueString(), &existing.Vpc)
+	return mismatches
+}
+
+// adoptApplication looks up an existing application with the planned name and cluster, and returns it
+// if it matches the planned application. It returns nil if there's no such application, and adds an
+// error if there's one which doesn't match or can't be checked.
+func (r *appResource) adoptApplication(ctx context.Context, client applications.Client, data appResourceModel, diags *diag.Diagnostics) *applications.InstanceDetails {
+	name, cluster := data.Name.ValueString(), data.Cluster.ValueString()
+	details, err := client.GetApplicationDetails(ctx, &applications.GetApplicationDetailsParams{Id: name, Cluster: cluster})
+	if err != nil {
+		if strings.Contains(err.Error(), fmt.Sprintf("\"%s\" not found", name)) {
+			return nil
+		}
+		diags.AddError(
+			"Unable to Look Up Existing Application",
+			fmt.Sprintf("adopt_existing is set, but application %q in cluster %s could not be looked up: %s", name, cluster, err),
+		)
+		return nil
+	}
+	if details == nil || details.InstanceDetails == nil {
+		return nil
+	}
+
+	// The framework doesn't tell Create the address of the resource, so the import command has a placeholder for it
+	if mismatches := appMismatches(data, *details.InstanceDetails); len(mismatches) > 0 {
+		diags.AddAttributeError(
+			path.Root("adopt_existing"),
+			"Existing Application Does Not Match",
+			fmt.Sprintf("An application named %q already exists in cluster %s, but it was not adopted because %s. ",
+				name, cluster, strings.Join(mismatches, " and "))+
+				"Import it instead to see the differences in the next plan, replacing ADDRESS with the address of this resource, "+
+				"e.g. denvr_app.example or module.apps.denvr_app.example, which Terraform doesn't pass to the provider:\n\n"+
+				fmt.Sprintf("    terraform import ADDRESS %s/%s", cluster, name),
+		)
+		return nil
+	}
+
+	tflog.Info(ctx, fmt.Sprintf("Adopting existing application %s/%s", cluster, name))
+	return details.InstanceDetails
+}
+
+// appMismatches describes how an existing application differs from the planned one. Custom
+// applications always mismatch, since the details of an application don't include its image, and
+// ValidateConfig rejects adopt_existing for them.
+func appMismatches(data appResourceModel, existing applications.InstanceDetails) []string {
+	var mismatches []string
+	mismatch := func(attr string, planned types.String, actual *string) {
+		if planned.IsNull() || planned.IsUnknown() {
+			return
+		}
+		if actual == nil {
+			mismatches = append(mismatches, fmt.Sprintf("its %s isn't returned by the Denvr API, so it can't be checked", attr))
+		} else if *actual != planned.ValueString() {
+			mismatches = append(mismatches, fmt.Sprintf("its %s is %q, not %q", attr, *actual, planned.ValueString()))
+		}
+	}
+
+	mismatch("hardware_package_name", data.HardwarePackageName, existing.HardwarePackage)
+	mismatch("resource_pool", data.ResourcePool, existing.ResourcePool)
+	if isCustomApplication(data) {
+		mismatch("image_url", data.ImageUrl, nil)
+		return mismatches
+	}
+
+	mismatch("application_catalog_item_name", data.ApplicationCatalogItemName, existing.ApplicationCatalogItemName)
+
+	// Compare with the version resolved during plan in case we were given "latest" or a constraint
+	version := data.ApplicationCatalogItemVersion
+	if data.ResolvedCatalogVersion.ValueString() != "" {
+		version = data.ResolvedCatalogVersion
+	}
+	mismatch("application_catalog_item_version", version, existing.ApplicationCatalogItemVersion)
+	return mismatches
+}
@@ -0,0 +1,236 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/denvrdata/go-denvr/api/v1/servers/applications"
+	"github.com/denvrdata/go-denvr/api/v1/servers/virtual"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestFindServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case denvrAuthPath:
+			fmt.Fprint(resp, `{"result": {"accessToken": "access1", "expireInSeconds": 600}}`)
+		case denvrServersPath:
+			switch req.URL.Query().Get("cluster") {
+			case "Msc1":
+				fmt.Fprint(resp, `{"result": {"items": [
+					{"id": "other-vm", "namespace": "denvr", "cluster": "Msc1", "configuration": "A100_40GB_SXM_1x", "rpool": "on-demand"},
+					{"id": "my-vm", "namespace": "denvr", "cluster": "Msc1", "configuration": "H100_80GB_SXM_8x", "rpool": "reserved", "vpc": "denvr-vpc"}
+				]}}`)
+			case "Hou1":
+				fmt.Fprint(resp, `{"result": []}`)
+			default:
+				resp.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(resp, `{"error": {"message": "unknown cluster"}}`)
+			}
+		}
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		cluster string
+		name    string
+		want    *existingServer
+		wantErr string
+	}{
+		{"Msc1", "my-vm", &existingServer{Id: "my-vm", Namespace: "denvr", Cluster: "Msc1", Configuration: "H100_80GB_SXM_8x", Rpool: "reserved", Vpc: "denvr-vpc"}, ""},
+		{"Msc1", "new-vm", nil, ""},
+		{"Hou1", "my-vm", nil, ""},
+		{"Nowhere", "my-vm", nil, "unknown cluster"},
+	}
+
+	client := newDenvrClient(denvrCredentials{Server: server.URL}, denvrClientOptions{})
+	for _, c := range cases {
+		t.Run(c.cluster+"/"+c.name, func(t *testing.T) {
+			got, err := client.findServer(context.Background(), c.cluster, c.name)
+			if c.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+				t.Fatalf("expected an error containing %q, got %v", c.wantErr, err)
+			}
+			if (got == nil) != (c.want == nil) || (got != nil && *got != *c.want) {
+				t.Errorf("expected %+v, got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestExistingServer_matchPlacement(t *testing.T) {
+	existing := existingServer{Id: "my-vm", Namespace: "denvr", Cluster: "Msc1", Configuration: "A100_40GB_SXM_8x", Rpool: "on-demand"}
+	msc1 := placement("Msc1", "A100_40GB_SXM_8x", "on-demand")
+	hou1 := placement("Hou1", "A100_40GB_SXM_8x", "on-demand")
+
+	cases := []struct {
+		name       string
+		placements []vmPlacementModel
+		want       bool
+	}{
+		{"configured", []vmPlacementModel{msc1}, true},
+		{"one of the preferences", []vmPlacementModel{hou1, msc1}, true},
+		{"other configuration", []vmPlacementModel{placement("Msc1", "H100_80GB_SXM_8x", "on-demand")}, false},
+		{"other resource pool", []vmPlacementModel{placement("Msc1", "A100_40GB_SXM_8x", "reserved")}, false},
+		{"other cluster", []vmPlacementModel{hou1}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := existing.matchPlacement(c.placements)
+			if ok != c.want || (ok && got != msc1) {
+				t.Errorf("expected %t, got %v, %t", c.want, got, ok)
+			}
+		})
+	}
+}
+
+func TestServerMismatches(t *testing.T) {
+	str := func(s string) *string { return &s }
+	storage := int64(100)
+	data := vmResourceModel{
+		OperatingSystemImage: types.StringValue("Ubuntu 22.04.4 LTS"),
+		RootDiskSize:         types.Int32Value(100),
+		Vpc:                  types.StringValue("denvr-vpc"),
+	}
+	existing := existingServer{Id: "my-vm", Namespace: "denvr", Cluster: "Msc1", Vpc: "denvr-vpc"}
+	server := virtual.Server{Image: str("Ubuntu 22.04.4 LTS"), Storage: &storage}
+
+	otherImage := server
+	otherImage.Image = str("Ubuntu 24.04 LTS")
+	noStorage := server
+	noStorage.Storage = nil
+	noVpc := existing
+	noVpc.Vpc = ""
+	otherVpc := existing
+	otherVpc.Vpc = "other-vpc"
+
+	cases := []struct {
+		name     string
+		existing existingServer
+		server   virtual.Server
+		want     []string
+	}{
+		{"matches", existing, server, nil},
+		{"other image", existing, otherImage, []string{"operating_system_image is"}},
+		{"other vpc", otherVpc, server, []string{"vpc is"}},
+		{"vpc not returned", noVpc, server, []string{"vpc isn't returned"}},
+		{"root disk size isn't checked", existing, noStorage, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := serverMismatches(data, c.existing, c.server)
+			if len(got) != len(c.want) {
+				t.Fatalf("expected %d mismatches, got %v", len(c.want), got)
+			}
+			for i, want := range c.want {
+				if !strings.Contains(got[i], "its "+want) {
+					t.Errorf("expected a mismatch containing %q, got %q", want, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAppMismatches(t *testing.T) {
+	str := func(s string) *string { return &s }
+	existing := applications.InstanceDetails{
+		ApplicationCatalogItemName:    str("jupyter-notebook"),
+		ApplicationCatalogItemVersion: str("python-3.11.9"),
+		HardwarePackage:               str("g-nvidia-1xa100-40gb-pcie-14vcpu-112gb"),
+		ResourcePool:                  str("on-demand"),
+	}
+	catalogApp := func(name, version, hardwarePackage string) appResourceModel {
+		return appResourceModel{
+			ApplicationCatalogItemName:    types.StringValue(name),
+			ApplicationCatalogItemVersion: types.StringValue(version),
+			HardwarePackageName:           types.StringValue(hardwarePackage),
+			ResourcePool:                  types.StringValue("on-demand"),
+		}
+	}
+
+	resolved := catalogApp("jupyter-notebook", "latest", "g-nvidia-1xa100-40gb-pcie-14vcpu-112gb")
+	resolved.ResolvedCatalogVersion = types.StringValue("python-3.11.9")
+	custom := appResourceModel{
+		ImageUrl:            types.StringValue("docker.io/library/nginx:latest"),
+		HardwarePackageName: types.StringValue("g-nvidia-1xa100-40gb-pcie-14vcpu-112gb"),
+		ResourcePool:        types.StringValue("reserved"),
+	}
+
+	cases := []struct {
+		name string
+		data appResourceModel
+		want []string
+	}{
+		{"matches", catalogApp("jupyter-notebook", "python-3.11.9", "g-nvidia-1xa100-40gb-pcie-14vcpu-112gb"), nil},
+		{"resolved version", resolved, nil},
+		{
+			"hardware package and version", catalogApp("jupyter-notebook", "python-3.12.1", "g-nvidia-8xh100-80gb-sxm-208vcpu-1800gb"),
+			[]string{"hardware_package_name is", "application_catalog_item_version is"},
+		},
+		{"custom", custom, []string{"resource_pool is", "image_url isn't returned"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := appMismatches(c.data, existing)
+			if len(got) != len(c.want) {
+				t.Fatalf("expected %d mismatches, got %v", len(c.want), got)
+			}
+			for i, want := range c.want {
+				if !strings.Contains(got[i], "its "+want) {
+					t.Errorf("expected a mismatch containing %q, got %q", want, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAppResource_ValidateConfig_adoptExisting(t *testing.T) {
+	ctx := context.Background()
+	var schemaResp resource.SchemaResponse
+	(&appResource{}).Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	s := schemaResp.Schema
+
+	objectType := s.Type().TerraformType(ctx).(tftypes.Object)
+	value := func(adopt bool, attr, v string) tftypes.Value {
+		attrs := make(map[string]tftypes.Value, len(objectType.AttributeTypes))
+		for name, typ := range objectType.AttributeTypes {
+			attrs[name] = tftypes.NewValue(typ, nil)
+		}
+		attrs["adopt_existing"] = tftypes.NewValue(tftypes.Bool, adopt)
+		attrs[attr] = tftypes.NewValue(tftypes.String, v)
+		return tftypes.NewValue(objectType, attrs)
+	}
+
+	cases := []struct {
+		name      string
+		config    tftypes.Value
+		wantError bool
+	}{
+		{"custom", value(true, "image_url", "docker.io/library/nginx:latest"), true},
+		{"custom without adopt_existing", value(false, "image_url", "docker.io/library/nginx:latest"), false},
+		{"catalog", value(true, "application_catalog_item_name", "jupyter-notebook"), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: c.config}}
+			resp := &resource.ValidateConfigResponse{}
+
+			(&appResource{}).ValidateConfig(ctx, req, resp)
+			if resp.Diagnostics.HasError() != c.wantError {
+				t.Fatalf("expected error %t, got %v", c.wantError, resp.Diagnostics)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// denvrAPISubsystem is the tflog subsystem for HTTP wire logs, whose level is set with denvrAPILogEnv.
+const (
+	denvrAPISubsystem = "denvr_api"
+	denvrAPILogEnv    = "TF_LOG_PROVIDER_DENVR_API"
+)
+
+// apiLogBodyMaxBytes truncates logged request and response bodies.
+const apiLogBodyMaxBytes = 64 * 1024
+
+// apiLogSecretRegexp matches JSON members whose names look like credentials, e.g. "password",
+// "accessToken", "jupyterToken" or "clientSecret". The whole member is masked by tflog.
+var apiLogSecretRegexp = regexp.MustCompile(`(?i)"[a-z_]*(password|token|secret)"\s*:\s*"(?:[^"\\]|\\.)*"`)
+
+type apiLogSecretsKey struct{}
+
+// withAPILogSecrets returns a context whose API requests mask the given values in the wire logs,
+// for secrets which can't be recognised by their JSON member name (e.g. sensitive environment variables).
+func withAPILogSecrets(ctx context.Context, secrets ...string) context.Context {
+	existing, _ := ctx.Value(apiLogSecretsKey{}).([]string)
+	all := append([]string{}, existing...)
+	for _, secret := range secrets {
+		if secret != "" {
+			all = append(all, secret)
+		}
+	}
+	return context.WithValue(ctx, apiLogSecretsKey{}, all)
+}
+
+// apiLogContext sets up the denvr_api subsystem with the masking rules for a request. Its level is
+// read from TF_LOG_PROVIDER_DENVR_API, and follows the provider's otherwise.
+func apiLogContext(ctx context.Context, secrets ...string) context.Context {
+	ctx = tflog.NewSubsystem(ctx, denvrAPISubsystem, tflog.WithLevelFromEnv("TF_LOG_PROVIDER", "DENVR", "API"))
+	ctx = tflog.SubsystemMaskAllFieldValuesRegexes(ctx, denvrAPISubsystem, apiLogSecretRegexp)
+
+	fromContext, _ := ctx.Value(apiLogSecretsKey{}).([]string)
+	var masked []string
+	for _, secret := range append(secrets, fromContext...) {
+		if secret != "" {
+			masked = append(masked, secret)
+		}
+	}
+	if len(masked) > 0 {
+		ctx = tflog.SubsystemMaskAllFieldValuesStrings(ctx, denvrAPISubsystem, masked...)
+	}
+	return ctx
+}
+
+// apiLogBodies reports whether request and response bodies are logged, which happens at trace
+// level only, so responses aren't buffered for nothing. It follows the same environment variables
+// as the subsystem level, falling back to the provider's and then Terraform's.
+func apiLogBodies() bool {
+	for _, env := range []string{denvrAPILogEnv, "TF_LOG_PROVIDER_DENVR", "TF_LOG_PROVIDER", "TF_LOG"} {
+		if value := os.Getenv(env); value != "" {
+			return strings.EqualFold(value, "JSON") || hclog.LevelFromString(value) == hclog.Trace
+		}
+	}
+	return false
+}
+
+// loggingTransport logs every request and response to the denvr_api subsystem, at debug level
+// and with their bodies at trace level.
+type loggingTransport struct {
+	base http.RoundTripper
+
+	// secrets are values known to the client which must never be logged, like the account password
+	secrets []string
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := apiLogContext(req.Context(), t.secrets...)
+
+	bodies := apiLogBodies()
+	log := tflog.SubsystemDebug
+	if bodies {
+		log = tflog.SubsystemTrace
+	}
+
+	fields := map[string]interface{}{
+		"method": req.Method,
+		"url":    req.URL.Redacted(),
+	}
+	if bodies && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			fields["request_body"] = readLogBody(body)
+		}
+	}
+	log(ctx, denvrAPISubsystem, "Sending Denvr API request", fields)
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	fields["latency_ms"] = time.Since(start).Milliseconds()
+
+	if err != nil {
+		fields["error"] = err.Error()
+		log(ctx, denvrAPISubsystem, "Denvr API request failed", fields)
+		return resp, err
+	}
+	fields["status"] = resp.StatusCode
+
+	if bodies {
+		// Buffer the response so it can be both logged and returned
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			fields["error"] = err.Error()
+			log(ctx, denvrAPISubsystem, "Denvr API request failed", fields)
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		fields["response_body"] = truncateLogBody(body)
+	}
+	log(ctx, denvrAPISubsystem, "Received Denvr API response", fields)
+
+	return resp, nil
+}
+
+func readLogBody(body io.ReadCloser) string {
+	defer body.Close()
+	content, _ := io.ReadAll(io.LimitReader(body, apiLogBodyMaxBytes+1))
+	return truncateLogBody(content)
+}
+
+func truncateLogBody(body []byte) string {
+	if len(body) > apiLogBodyMaxBytes {
+		return string(body[:apiLogBodyMaxBytes]) + "...(truncated)"
+	}
+	return string(body)
+}
@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+)
+
+func TestLoggingTransport(t *testing.T) {
+	t.Setenv("TF_LOG_PROVIDER_DENVR_API", "TRACE")
+
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(resp, `{"result": {"accessToken": "eyJhbGciOi.secret", "expireInSeconds": 3600}}`)
+	}))
+	defer server.Close()
+
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+	ctx = withAPILogSecrets(ctx, "s3cr3t-env-value")
+
+	client := &http.Client{Transport: &loggingTransport{base: http.DefaultTransport, secrets: []string{"hunter2"}}}
+	body := `{"userNameOrEmailAddress": "test@foobar.com", "password": "hunter2", "environmentVariables": {"API_KEY": "s3cr3t-env-value"}}`
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/TokenAuth/Authenticate", strings.NewReader(body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// The response body must still be readable after being logged
+	if content, _ := io.ReadAll(resp.Body); !strings.Contains(string(content), "eyJhbGciOi.secret") {
+		t.Errorf("expected the response body to be returned unchanged, got %q", content)
+	}
+
+	entries, err := tflogtest.MultilineJSONDecode(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected a request and a response log entry, got %d: %v", len(entries), entries)
+	}
+
+	request, response := entries[0], entries[1]
+	if request["@module"] != "provider."+denvrAPISubsystem || request["method"] != "POST" || !strings.HasSuffix(request["url"].(string), "/api/TokenAuth/Authenticate") {
+		t.Errorf("unexpected request entry: %v", request)
+	}
+	if response["status"] != float64(200) || response["latency_ms"] == nil {
+		t.Errorf("unexpected response entry: %v", response)
+	}
+
+	logged := output.String() + fmt.Sprint(entries)
+	for _, secret := range []string{"hunter2", "s3cr3t-env-value", "eyJhbGciOi.secret"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("expected %q to be masked, got %v", secret, entries)
+		}
+	}
+	if !strings.Contains(request["request_body"].(string), "test@foobar.com") {
+		t.Errorf("expected the non-secret request body to be logged, got %v", request["request_body"])
+	}
+}
+
+func TestLoggingTransport_debug(t *testing.T) {
+	t.Setenv("TF_LOG_PROVIDER_DENVR_API", "DEBUG")
+
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(resp, `{"result": {"items": []}}`)
+	}))
+	defer server.Close()
+
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader(`{"name": "my-vm"}`))
+
+	resp, err := (&http.Client{Transport: &loggingTransport{base: http.DefaultTransport}}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if content, _ := io.ReadAll(resp.Body); string(content) != `{"result": {"items": []}}` {
+		t.Errorf("expected the response body to be returned unchanged, got %q", content)
+	}
+
+	entries, err := tflogtest.MultilineJSONDecode(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected a request and a response log entry, got %d: %v", len(entries), entries)
+	}
+	if entries[0]["request_body"] != nil || entries[1]["response_body"] != nil || entries[1]["status"] != float64(200) {
+		t.Errorf("expected the status without bodies at debug level, got %v", entries)
+	}
+}
+
+func TestLoggingTransport_disabled(t *testing.T) {
+	t.Setenv("TF_LOG_PROVIDER_DENVR_API", "OFF")
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+
+	resp, err := (&http.Client{Transport: &loggingTransport{base: http.DefaultTransport}}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if output.Len() != 0 {
+		t.Errorf("expected no wire logs with TF_LOG_PROVIDER_DENVR_API=OFF, got %s", output.String())
+	}
+}
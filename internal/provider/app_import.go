@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/denvrdata/go-denvr/api/v1/servers/applications"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+)
+
+// ImportState imports an application by "<cluster>/<name>", e.g. "Msc1/my-app". The attributes which
+// identify the application are filled in from its details, while its image and settings come from
+// the configuration.
+func (r *appResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	if r.client == nil {
+		resp.Deferred = deferUnconfigured(req.ClientCapabilities.DeferralAllowed, &resp.Diagnostics)
+		return
+	}
+
+	cluster, name, ok := parseAppImportID(req.ID)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Invalid Import ID",
+			fmt.Sprintf("Expected an import ID of the form \"<cluster>/<name>\", got %q", req.ID),
+		)
+		return
+	}
+
+	details, err := r.client.applications().GetApplicationDetails(ctx, &applications.GetApplicationDetailsParams{Id: name, Cluster: cluster})
+	if err != nil {
+		resp.Diagnostics.AddError("Error getting application", err.Error())
+		return
+	} else if details == nil || details.InstanceDetails == nil {
+		resp.Diagnostics.AddError("Error getting application", fmt.Sprintf("Application %q in cluster %s was not found", name, cluster))
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("cluster"), cluster)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), name)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name"), name)...)
+	for attr, value := range importedAttributes(*details.InstanceDetails) {
+		if value != nil {
+			resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root(attr), *value)...)
+		}
+	}
+}
+
+// parseAppImportID splits an import ID of the form "<cluster>/<name>", and returns false if it isn't one.
+func parseAppImportID(id string) (cluster, name string, ok bool) {
+	cluster, name, ok = strings.Cut(id, "/")
+	if !ok || cluster == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", false
+	}
+	return cluster, name, true
+}
+
+// importedAttributes returns the attributes which identify an imported application from its
+// details, keyed by attribute name. Values the details don't include are nil.
+func importedAttributes(details applications.InstanceDetails) map[string]*string {
+	return map[string]*string{
+		"hardware_package_name":            details.HardwarePackage,
+		"resource_pool":                    details.ResourcePool,
+		"application_catalog_item_name":    details.ApplicationCatalogItemName,
+		"application_catalog_item_version": details.ApplicationCatalogItemVersion,
+		"resolved_catalog_version":         details.ApplicationCatalogItemVersion,
+	}
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/denvrdata/go-denvr/api/v1/servers/applications"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestAppResource_ImportState(t *testing.T) {
+	ctx := context.Background()
+	var schemaResp resource.SchemaResponse
+	(&appResource{}).Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	cases := []struct {
+		name         string
+		client       *denvrClient
+		id           string
+		deferral     bool
+		wantDeferred bool
+		wantErr      bool
+	}{
+		{"invalid id", &denvrClient{}, "my-app", false, false, true},
+		{"unconfigured", nil, "Msc1/my-app", true, true, false},
+		{"unconfigured without deferral", nil, "Msc1/my-app", false, false, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := &appResource{client: c.client}
+			req := resource.ImportStateRequest{ID: c.id}
+			req.ClientCapabilities.DeferralAllowed = c.deferral
+			resp := &resource.ImportStateResponse{
+				State: tfsdk.State{
+					Schema: schemaResp.Schema,
+					Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+				},
+			}
+
+			r.ImportState(ctx, req, resp)
+			if resp.Diagnostics.HasError() != c.wantErr {
+				t.Fatalf("expected error %t, got %v", c.wantErr, resp.Diagnostics)
+			}
+			if (resp.Deferred != nil) != c.wantDeferred {
+				t.Errorf("expected deferred %t, got %v", c.wantDeferred, resp.Deferred)
+			}
+		})
+	}
+}
+
+func TestParseAppImportID(t *testing.T) {
+	cases := []struct {
+		id      string
+		cluster string
+		name    string
+		ok      bool
+	}{
+		{"Msc1/my-app", "Msc1", "my-app", true},
+		{"my-app", "", "", false},
+		{"Msc1/", "", "", false},
+		{"/my-app", "", "", false},
+		{"Msc1/denvr/my-app", "", "", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.id, func(t *testing.T) {
+			cluster, name, ok := parseAppImportID(c.id)
+			if cluster != c.cluster || name != c.name || ok != c.ok {
+				t.Errorf("expected %q, %q, %t, got %q, %q, %t", c.cluster, c.name, c.ok, cluster, name, ok)
+			}
+		})
+	}
+}
+
+func TestImportedAttributes(t *testing.T) {
+	str := func(s string) *string { return &s }
+	details := applications.InstanceDetails{
+		ApplicationCatalogItemName:    str("jupyter-notebook"),
+		ApplicationCatalogItemVersion: str("python-3.11.9"),
+		HardwarePackage:               str("g-nvidia-1xa100-40gb-pcie-14vcpu-112gb"),
+	}
+
+	want := map[string]string{
+		"hardware_package_name":            "g-nvidia-1xa100-40gb-pcie-14vcpu-112gb",
+		"application_catalog_item_name":    "jupyter-notebook",
+		"application_catalog_item_version": "python-3.11.9",
+		"resolved_catalog_version":         "python-3.11.9",
+	}
+	for attr, value := range importedAttributes(details) {
+		if value == nil {
+			if _, ok := want[attr]; ok {
+				t.Errorf("expected %s to be %q, got nil", attr, want[attr])
+			}
+		} else if *value != want[attr] {
+			t.Errorf("expected %s to be %q, got %q", attr, want[attr], *value)
+		}
+	}
+}
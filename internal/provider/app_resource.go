@@ -2,61 +2,105 @@ package provider
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"net/http"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/denvrdata/go-denvr/api/v1/servers/applications"
+	"github.com/hashicorp/terraform-plugin-framework-validators/boolvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int32validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/mapvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/resourcevalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64default"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listdefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 var (
-	_ resource.Resource = &appResource{}
+	_ resource.Resource                     = &appResource{}
+	_ resource.ResourceWithConfigure        = &appResource{}
+	_ resource.ResourceWithConfigValidators = &appResource{}
+	_ resource.ResourceWithImportState      = &appResource{}
+	_ resource.ResourceWithValidateConfig   = &appResource{}
+	_ resource.ResourceWithModifyPlan       = &appResource{}
 )
 
-type appResource struct{}
+// Catalog applications are identified by application_catalog_item_name while custom
+// applications are identified by image_url. Fields specific to one mode conflict with
+// the identifying attribute of the other mode.
+var (
+	catalogAppPath = path.MatchRoot("application_catalog_item_name")
+	customAppPath  = path.MatchRoot("image_url")
+)
+
+// imageRepositoryAuthDockerConfig resolves registry credentials from the local docker config.
+const imageRepositoryAuthDockerConfig = "docker_config"
+
+type appResource struct {
+	client *denvrClient
+}
 
 type appResourceModel struct {
-	ApplicationCatalogItemName    types.String `tfsdk:"application_catalog_item_name"`
-	ApplicationCatalogItemVersion types.String `tfsdk:"application_catalog_item_version"`
-	Cluster                       types.String `tfsdk:"cluster"`
-	Dns                           types.String `tfsdk:"dns"`
-	EnvironmentVariables          types.Map    `tfsdk:"environment_variables"`
-	HardwarePackageName           types.String `tfsdk:"hardware_package_name"`
-	ImageCmdOverride              types.List   `tfsdk:"image_cmd_override"`
-	ImageRepositoryHostname       types.String `tfsdk:"image_repository_hostname"`
-	ImageRepositoryPassword       types.String `tfsdk:"image_repository_password"`
-	ImageRepositoryUsername       types.String `tfsdk:"image_repository_username"`
-	ImageUrl                      types.String `tfsdk:"image_url"`
-	JupyterToken                  types.String `tfsdk:"jupyter_token"`
-	Id                            types.String `tfsdk:"id"`
-	Ip                            types.String `tfsdk:"ip"`
-	Name                          types.String `tfsdk:"name"`
-	PersistDirectAttachedStorage  types.Bool   `tfsdk:"persist_direct_attached_storage"`
-	PersonalSharedStorage         types.Bool   `tfsdk:"personal_shared_storage"`
-	PrivateIp                     types.String `tfsdk:"private_ip"`
-	ProxyPort                     types.Int32  `tfsdk:"proxy_port"`
-	ReadinessWatcherPort          types.Int32  `tfsdk:"readiness_watcher_port"`
-	ResourcePool                  types.String `tfsdk:"resource_pool"`
-	SecurityContextContainerGid   types.Int32  `tfsdk:"security_context_container_gid"`
-	SecurityContextContainerUid   types.Int32  `tfsdk:"security_context_container_uid"`
-	SecurityContextRunAsRoot      types.Bool   `tfsdk:"security_context_run_as_root"`
-	SshKeys                       types.List   `tfsdk:"ssh_keys"`
-	Status                        types.String `tfsdk:"status"`
-	Tenant                        types.String `tfsdk:"tenant"`
-	TenantSharedStorage           types.Bool   `tfsdk:"tenant_shared_storage"`
-	Username                      types.String `tfsdk:"username"`
-	Wait                          types.Bool   `tfsdk:"wait"`
-	Interval                      types.Int64  `tfsdk:"interval"`
-	Timeout                       types.Int64  `tfsdk:"timeout"`
+	AdoptExisting                    types.Bool   `tfsdk:"adopt_existing"`
+	ApplicationCatalogItemName       types.String `tfsdk:"application_catalog_item_name"`
+	ApplicationCatalogItemVersion    types.String `tfsdk:"application_catalog_item_version"`
+	Cluster                          types.String `tfsdk:"cluster"`
+	DeletionProtection               types.Bool   `tfsdk:"deletion_protection"`
+	Dns                              types.String `tfsdk:"dns"`
+	EnvironmentVariables             types.Map    `tfsdk:"environment_variables"`
+	HardwarePackageName              types.String `tfsdk:"hardware_package_name"`
+	ImageCmdOverride                 types.List   `tfsdk:"image_cmd_override"`
+	ImageDigest                      types.String `tfsdk:"image_digest"`
+	ImageRepositoryAuth              types.String `tfsdk:"image_repository_auth"`
+	ImageRepositoryHostname          types.String `tfsdk:"image_repository_hostname"`
+	ImageRepositoryPassword          types.String `tfsdk:"image_repository_password"`
+	ImageRepositoryPasswordWo        types.String `tfsdk:"image_repository_password_wo"`
+	ImageRepositoryPasswordWoVersion types.Int64  `tfsdk:"image_repository_password_wo_version"`
+	ImageRepositoryUsername          types.String `tfsdk:"image_repository_username"`
+	ImageUrl                         types.String `tfsdk:"image_url"`
+	JupyterToken                     types.String `tfsdk:"jupyter_token"`
+	JupyterTokenWo                   types.String `tfsdk:"jupyter_token_wo"`
+	JupyterTokenWoVersion            types.Int64  `tfsdk:"jupyter_token_wo_version"`
+	Id                               types.String `tfsdk:"id"`
+	Ip                               types.String `tfsdk:"ip"`
+	Name                             types.String `tfsdk:"name"`
+	PersistDirectAttachedStorage     types.Bool   `tfsdk:"persist_direct_attached_storage"`
+	PersonalSharedStorage            types.Bool   `tfsdk:"personal_shared_storage"`
+	PrivateIp                        types.String `tfsdk:"private_ip"`
+	ProxyPort                        types.Int32  `tfsdk:"proxy_port"`
+	ReadinessWatcherPort             types.Int32  `tfsdk:"readiness_watcher_port"`
+	ResolveDigest                    types.Bool   `tfsdk:"resolve_digest"`
+	ResolvedCatalogVersion           types.String `tfsdk:"resolved_catalog_version"`
+	ResourcePool                     types.String `tfsdk:"resource_pool"`
+	SecurityContextContainerGid      types.Int32  `tfsdk:"security_context_container_gid"`
+	SecurityContextContainerUid      types.Int32  `tfsdk:"security_context_container_uid"`
+	SecurityContextRunAsRoot         types.Bool   `tfsdk:"security_context_run_as_root"`
+	SensitiveEnvironmentVariables    types.Map    `tfsdk:"sensitive_environment_variables"`
+	SshKeys                          types.List   `tfsdk:"ssh_keys"`
+	Status                           types.String `tfsdk:"status"`
+	Tenant                           types.String `tfsdk:"tenant"`
+	TenantSharedStorage              types.Bool   `tfsdk:"tenant_shared_storage"`
+	Username                         types.String `tfsdk:"username"`
+	Wait                             types.Bool   `tfsdk:"wait"`
+	WaitForCapacityTimeout           types.String `tfsdk:"wait_for_capacity_timeout"`
+	Interval                         types.Int64  `tfsdk:"interval"`
+	Timeout                          types.Int64  `tfsdk:"timeout"`
 }
 
 func NewAppResource() resource.Resource {
@@ -65,6 +109,13 @@ func NewAppResource() resource.Resource {
 
 func (r *appResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
 	resp.TypeName = req.ProviderTypeName + "_app"
+
+	// Still plan attributes which don't need the API (e.g. image_repository_hostname) when the provider is deferred
+	resp.ResourceBehavior.ProviderDeferred.EnablePlanModification = true
+}
+
+func (r *appResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = configureClient(req, resp)
 }
 
 func (r *appResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
@@ -72,15 +123,23 @@ func (r *appResource) Schema(ctx context.Context, req resource.SchemaRequest, re
 		MarkdownDescription: "App resource schema",
 		Description:         "Schema for App resource configuration and management",
 		Attributes: map[string]schema.Attribute{
+			"adopt_existing": adoptExistingAttribute("application",
+				"The Denvr API doesn't return the image of an application, so it can't be set along with `image_url`."),
 			"application_catalog_item_name": schema.StringAttribute{
 				Optional: true,
 			},
 			"application_catalog_item_version": schema.StringAttribute{
+				MarkdownDescription: "An exact catalog version name (e.g. `python-3.11.9`), `latest` or a version constraint (e.g. `~> 3.11`) " +
+					"which is resolved against the application catalog during plan.",
 				Optional: true,
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(customAppPath),
+				},
 			},
 			"cluster": schema.StringAttribute{
 				Required: true,
 			},
+			"deletion_protection": deletionProtectionAttribute("application"),
 			"dns": schema.StringAttribute{
 				Computed: true,
 			},
@@ -89,6 +148,9 @@ func (r *appResource) Schema(ctx context.Context, req resource.SchemaRequest, re
 				Computed:    true,
 				ElementType: types.StringType,
 				Default:     mapdefault.StaticValue(types.MapValueMust(types.StringType, nil)),
+				Validators: []validator.Map{
+					mapvalidator.ConflictsWith(catalogAppPath),
+				},
 			},
 			"hardware_package_name": schema.StringAttribute{
 				Required: true,
@@ -98,21 +160,102 @@ func (r *appResource) Schema(ctx context.Context, req resource.SchemaRequest, re
 				Computed:    true,
 				ElementType: types.StringType,
 				Default:     listdefault.StaticValue(types.ListValueMust(types.StringType, nil)),
+				Validators: []validator.List{
+					listvalidator.ConflictsWith(catalogAppPath),
+				},
 			},
-			"image_repository_hostname": schema.StringAttribute{
+			"image_digest": schema.StringAttribute{
+				MarkdownDescription: "The manifest digest `image_url` resolved to when `resolve_digest` is enabled.",
+				Computed:            true,
+			},
+			"image_repository_auth": schema.StringAttribute{
+				MarkdownDescription: "Set to `docker_config` to resolve the registry credentials for `image_url` from the docker config file " +
+					"(`$DOCKER_CONFIG/config.json` or `~/.docker/config.json`), including `credsStore` and `credHelpers`.",
 				Optional: true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(imageRepositoryAuthDockerConfig),
+					stringvalidator.ConflictsWith(
+						catalogAppPath,
+						path.MatchRoot("image_repository_username"),
+						path.MatchRoot("image_repository_password"),
+						path.MatchRoot("image_repository_password_wo"),
+					),
+				},
+			},
+			"image_repository_hostname": schema.StringAttribute{
+				MarkdownDescription: "Defaults to the registry hostname of `image_url`.",
+				Optional:            true,
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					imageRepositoryHostnameModifier{},
+				},
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(catalogAppPath),
+				},
 			},
 			"image_repository_password": schema.StringAttribute{
-				Optional: true,
+				Optional:  true,
+				Sensitive: true,
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(catalogAppPath),
+				},
+			},
+			"image_repository_password_wo": schema.StringAttribute{
+				MarkdownDescription: "Write-only alternative to `image_repository_password` which is never stored in state. Requires Terraform 1.11 or later.",
+				Optional:            true,
+				Sensitive:           true,
+				WriteOnly:           true,
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(catalogAppPath, path.MatchRoot("image_repository_password")),
+				},
+			},
+			"image_repository_password_wo_version": schema.Int64Attribute{
+				MarkdownDescription: "Change this value to send a new `image_repository_password_wo`. Changing it replaces the application.",
+				Optional:            true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+				Validators: []validator.Int64{
+					int64validator.AlsoRequires(path.MatchRoot("image_repository_password_wo")),
+				},
 			},
 			"image_repository_username": schema.StringAttribute{
 				Optional: true,
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(catalogAppPath),
+				},
 			},
 			"image_url": schema.StringAttribute{
 				Optional: true,
+				Validators: []validator.String{
+					imageReferenceValidator{},
+				},
 			},
 			"jupyter_token": schema.StringAttribute{
-				Optional: true,
+				Optional:  true,
+				Sensitive: true,
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(customAppPath),
+				},
+			},
+			"jupyter_token_wo": schema.StringAttribute{
+				MarkdownDescription: "Write-only alternative to `jupyter_token` which is never stored in state. Requires Terraform 1.11 or later.",
+				Optional:            true,
+				Sensitive:           true,
+				WriteOnly:           true,
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(customAppPath, path.MatchRoot("jupyter_token")),
+				},
+			},
+			"jupyter_token_wo_version": schema.Int64Attribute{
+				MarkdownDescription: "Change this value to send a new `jupyter_token_wo`. Changing it replaces the application.",
+				Optional:            true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+				Validators: []validator.Int64{
+					int64validator.AlsoRequires(path.MatchRoot("jupyter_token_wo")),
+				},
 			},
 			"id": schema.StringAttribute{
 				Computed: true,
@@ -138,21 +281,59 @@ func (r *appResource) Schema(ctx context.Context, req resource.SchemaRequest, re
 			},
 			"proxy_port": schema.Int32Attribute{
 				Optional: true,
+				Validators: []validator.Int32{
+					int32validator.ConflictsWith(catalogAppPath),
+				},
 			},
 			"readiness_watcher_port": schema.Int32Attribute{
 				Optional: true,
+				Validators: []validator.Int32{
+					int32validator.ConflictsWith(catalogAppPath),
+				},
+			},
+			"resolve_digest": schema.BoolAttribute{
+				MarkdownDescription: "Resolve the `image_url` tag to a manifest digest from the image registry during plan and pin the application to it. " +
+					"The application is replaced when the tag moves to a new digest.",
+				Optional: true,
+				Computed: true,
+				Default:  booldefault.StaticBool(false),
+				Validators: []validator.Bool{
+					boolvalidator.ConflictsWith(catalogAppPath),
+				},
+			},
+			"resolved_catalog_version": schema.StringAttribute{
+				MarkdownDescription: "The catalog version name `application_catalog_item_version` resolved to. A newer matching version replaces the application.",
+				Computed:            true,
 			},
 			"resource_pool": schema.StringAttribute{
 				Required: true,
 			},
 			"security_context_container_gid": schema.Int32Attribute{
 				Optional: true,
+				Validators: []validator.Int32{
+					int32validator.ConflictsWith(catalogAppPath),
+				},
 			},
 			"security_context_container_uid": schema.Int32Attribute{
 				Optional: true,
+				Validators: []validator.Int32{
+					int32validator.ConflictsWith(catalogAppPath),
+				},
 			},
 			"security_context_run_as_root": schema.BoolAttribute{
 				Optional: true,
+				Validators: []validator.Bool{
+					boolvalidator.ConflictsWith(catalogAppPath),
+				},
+			},
+			"sensitive_environment_variables": schema.MapAttribute{
+				MarkdownDescription: "Environment variables which are merged with `environment_variables`, but hidden from plan output. Keys must not also appear in `environment_variables`.",
+				Optional:            true,
+				Sensitive:           true,
+				ElementType:         types.StringType,
+				Validators: []validator.Map{
+					mapvalidator.ConflictsWith(catalogAppPath),
+				},
 			},
 			"ssh_keys": schema.ListAttribute{
 				Optional:    true,
@@ -177,10 +358,14 @@ func (r *appResource) Schema(ctx context.Context, req resource.SchemaRequest, re
 				Computed: true,
 				Default:  booldefault.StaticBool(false),
 			},
+			"wait_for_capacity_timeout": waitForCapacityTimeoutAttribute("application"),
 			"interval": schema.Int64Attribute{
 				Optional: true,
 				Computed: true,
 				Default:  int64default.StaticInt64(30),
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
 			},
 			"timeout": schema.Int64Attribute{
 				Optional: true,
@@ -191,66 +376,254 @@ func (r *appResource) Schema(ctx context.Context, req resource.SchemaRequest, re
 	}
 }
 
-func (r *appResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	tflog.Debug(ctx, "Reading Terraform plan data into appResourceModel")
-	var data appResourceModel
-	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+func (r *appResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		resourcevalidator.ExactlyOneOf(catalogAppPath, customAppPath),
+	}
+}
+
+func (r *appResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	// Custom applications can never be adopted, since their image can't be compared
+	var adoptExisting types.Bool
+	var imageUrl types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("adopt_existing"), &adoptExisting)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("image_url"), &imageUrl)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	if adoptExisting.ValueBool() && !imageUrl.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("adopt_existing"),
+			"Invalid Attribute Combination",
+			"adopt_existing can't be set for an application with image_url, since the Denvr API doesn't return the image of an "+
+				"existing application to check it against.",
+		)
+	}
 
-	tflog.Debug(ctx, "Constructing application client")
-	client := applications.NewClient()
+	var envVars, sensitiveEnvVars types.Map
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("environment_variables"), &envVars)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("sensitive_environment_variables"), &sensitiveEnvVars)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	var app *applications.ApplicationsApiOverview
-	var err error
+	// Keys can only be compared once both maps are known
+	if envVars.IsNull() || envVars.IsUnknown() || sensitiveEnvVars.IsNull() || sensitiveEnvVars.IsUnknown() {
+		return
+	}
 
-	// If an image repository hostname is provided then we must be creating a custom application.
-	// Otherwise fallback to trying to create a catalog application.
-	// If this assumption is wrong we should get an error we can provide to the user.
-	if data.ImageRepositoryHostname.ValueString() != "" {
-		app, err = createCustomApplication(ctx, client, data)
-	} else {
-		app, err = createCatalogApplication(ctx, client, data)
+	for key := range sensitiveEnvVars.Elements() {
+		if _, ok := envVars.Elements()[key]; ok {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("sensitive_environment_variables").AtMapKey(key),
+				"Duplicate environment variable",
+				fmt.Sprintf("Environment variable %q is set in both environment_variables and sensitive_environment_variables.", key),
+			)
+		}
 	}
-	if err != nil {
-		resp.Diagnostics.AddError("Error creating application", err.Error())
+}
+
+func (r *appResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Nothing to do when destroying
+	if req.Plan.Raw.IsNull() {
 		return
-	} else if app == nil {
-		resp.Diagnostics.AddError("Error creating application", "Application was not created and no error was returned.")
+	}
+
+	// Runs last, once every replacement has been planned
+	defer checkReplaceProtection(ctx, "application", req, resp)
+
+	var plan appResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
 		return
-	} else if app.Id == nil {
-		resp.Diagnostics.AddError("Error creating application", "Returned application Id is nil")
+	}
+
+	if r.client == nil {
+		resp.Deferred = deferUnconfigured(req.ClientCapabilities.DeferralAllowed, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	} else {
+		var state *appResourceModel
+		if !req.State.Raw.IsNull() {
+			state = &appResourceModel{}
+			resp.Diagnostics.Append(req.State.Get(ctx, state)...)
+		}
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		r.client.policy.checkApp(plan, state, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	if !plan.ResolveDigest.ValueBool() {
+		plan.ImageDigest = types.StringNull()
+	} else if plan.ImageUrl.IsUnknown() || plan.ImageRepositoryUsername.IsUnknown() || plan.ImageRepositoryPassword.IsUnknown() {
+		plan.ImageDigest = types.StringUnknown()
+	} else {
+		reqData, diags := requestModel(ctx, req.Config, plan)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		ref, err := parseImageReference(reqData.ImageUrl.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("image_url"), "Invalid Image Reference", err.Error())
+			return
+		}
+
+		tflog.Debug(ctx, "Resolving image digest for "+reqData.ImageUrl.ValueString())
+		var transport http.RoundTripper
+		if r.client != nil {
+			transport = r.client.transport
+		}
+		registry := newRegistryClient(transport, reqData.ImageRepositoryUsername.ValueString(), reqData.ImageRepositoryPassword.ValueString())
+		digest, err := registry.resolveDigest(ctx, ref)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("image_url"), "Error resolving image digest", err.Error())
+			return
+		}
+		plan.ImageDigest = types.StringValue(digest)
+	}
+
+	version := plan.ApplicationCatalogItemVersion
+	if version.IsNull() {
+		plan.ResolvedCatalogVersion = types.StringNull()
+	} else if version.IsUnknown() || plan.ApplicationCatalogItemName.IsUnknown() {
+		plan.ResolvedCatalogVersion = types.StringUnknown()
+	} else if !isCatalogVersionConstraint(version.ValueString()) {
+		plan.ResolvedCatalogVersion = version
+	} else if r.client == nil {
+		// Resolved once the provider configuration is known
+		plan.ResolvedCatalogVersion = types.StringUnknown()
+	} else {
+		tflog.Debug(ctx, "Resolving application catalog version "+version.ValueString())
+		items, err := r.client.applications().GetApplicationCatalogItems(ctx)
+		if err != nil {
+			resp.Diagnostics.AddError("Error getting application catalog", err.Error())
+			return
+		} else if items == nil {
+			resp.Diagnostics.AddError("Error getting application catalog", "Returned application catalog is nil")
+			return
+		}
+
+		resolved, err := resolveCatalogVersion(*items, plan.ApplicationCatalogItemName.ValueString(), version.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("application_catalog_item_version"), "Error resolving application catalog version", err.Error())
+			return
+		}
+		plan.ResolvedCatalogVersion = types.StringValue(resolved)
+	}
+
+	// A new digest or catalog version means the image has changed, which requires a new application
+	if !req.State.Raw.IsNull() {
+		var state appResourceModel
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		planResolvedReplace(&plan, state, resp)
+	}
+
+	// Only new applications draw on capacity, and values which aren't known yet are checked during apply
+	if r.client != nil && (req.State.Raw.IsNull() || len(resp.RequiresReplace) > 0) &&
+		!plan.Cluster.IsUnknown() && !plan.ResourcePool.IsUnknown() && !plan.HardwarePackageName.IsUnknown() && !plan.Name.IsUnknown() {
+		pool := capacityPool{Cluster: plan.Cluster.ValueString(), ResourcePool: plan.ResourcePool.ValueString()}
+		gpus, _ := parseGPUCount(hardwarePackageGPUsRegexp, plan.HardwarePackageName.ValueString())
+		r.client.checkCapacity(ctx, pool, "denvr_app", plan.Name.ValueString(), gpus, &resp.Diagnostics)
+	}
+
+	resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
+}
+
+// planResolvedReplace requires replacing the application when its image digest or catalog version
+// resolves differently to the prior state. State without them, e.g. from an older provider version,
+// keeps its null values rather than replacing applications which haven't changed.
+func planResolvedReplace(plan *appResourceModel, state appResourceModel, resp *resource.ModifyPlanResponse) {
+	compare := func(attr string, planned *types.String, prior types.String) {
+		if prior.IsNull() || prior.IsUnknown() {
+			*planned = prior
+		} else if !planned.Equal(prior) {
+			resp.RequiresReplace = append(resp.RequiresReplace, path.Root(attr))
+		}
+	}
+
+	compare("image_digest", &plan.ImageDigest, state.ImageDigest)
+	compare("resolved_catalog_version", &plan.ResolvedCatalogVersion, state.ResolvedCatalogVersion)
+}
+
+func (r *appResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	if r.client.checkReadOnly("Creating an application", &resp.Diagnostics) {
 		return
-	} else if app.Cluster == nil {
-		resp.Diagnostics.AddError("Error creating application", "Returned application Cluster is nil")
+	}
+
+	tflog.Debug(ctx, "Reading Terraform plan data into appResourceModel")
+	var data appResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	appJson, err := json.MarshalIndent(app, "", "\t")
-	if err != nil {
-		resp.Diagnostics.AddError("Error marshaling application response", err.Error())
+	ctx, span := r.client.startSpan(ctx, "denvr_app.Create",
+		attrCluster.String(data.Cluster.ValueString()),
+		attrConfiguration.String(data.HardwarePackageName.ValueString()),
+	)
+	defer func() { r.client.endOperation(ctx, span, resp.Diagnostics) }()
+
+	reqData, diags := requestModel(ctx, req.Config, data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
-	tflog.Debug(ctx, string(appJson))
 
-	data = updateState(ctx, data, *app)
+	tflog.Debug(ctx, "Constructing application client")
+	client := r.client.applications()
+
+	// An application left behind by an interrupted apply is taken into state instead of created again
+	var adopted *applications.InstanceDetails
+	if data.AdoptExisting.ValueBool() {
+		adopted = r.adoptApplication(ctx, client, reqData, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+	if adopted != nil {
+		data = updateState(ctx, data, *adopted)
+	} else {
+		app := r.create(ctx, client, reqData, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		data = updateState(ctx, data, *app)
+	}
+	span.SetAttributes(attrResourceID.String(data.Id.ValueString()), attrStatus.String(data.Status.ValueString()))
 
 	if data.Wait.ValueBool() {
 		tflog.Debug(ctx, "Waiting for application to be ready")
 		getParams := applications.GetApplicationDetailsParams{
-			Id:      *app.Id,
-			Cluster: *app.Cluster,
+			Id:      data.Id.ValueString(),
+			Cluster: data.Cluster.ValueString(),
 		}
 
 		start := time.Now()
-		for {
+		for attempt := 1; ; attempt++ {
 			if time.Since(start) > (time.Duration(data.Timeout.ValueInt64()) * time.Second) {
 				resp.Diagnostics.AddError("Timeout Error", "Waiting for application to come \"ONLINE\" timed out")
 				return
 			}
 
-			details, err := client.GetApplicationDetails(ctx, &getParams)
+			pollCtx, poll := r.client.startSpan(ctx, "denvr_app.poll", attrPollAttempt.Int(attempt))
+			details, err := client.GetApplicationDetails(pollCtx, &getParams)
+			if err == nil && details != nil && details.InstanceDetails != nil && details.InstanceDetails.Status != nil {
+				poll.SetAttributes(attrStatus.String(*details.InstanceDetails.Status))
+				span.SetAttributes(attrStatus.String(*details.InstanceDetails.Status))
+			}
+			endSpan(poll, err)
 			if err != nil {
 				resp.Diagnostics.AddError("Error checking application status", err.Error())
 				return
@@ -265,13 +638,6 @@ func (r *appResource) Create(ctx context.Context, req resource.CreateRequest, re
 				return
 			}
 
-			detailsJson, err := json.MarshalIndent(*details.InstanceDetails, "", "\t")
-			if err != nil {
-				resp.Diagnostics.AddError("Error marshaling application details", err.Error())
-				return
-			}
-			tflog.Debug(ctx, string(detailsJson))
-
 			if *details.InstanceDetails.Status == "ONLINE" {
 				data = updateState(ctx, data, *details.InstanceDetails)
 				break
@@ -286,7 +652,56 @@ func (r *appResource) Create(ctx context.Context, req resource.CreateRequest, re
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
+// create creates the application, retrying while the cluster is out of capacity if wait_for_capacity_timeout is set.
+func (r *appResource) create(ctx context.Context, client applications.Client, reqData appResourceModel, diags *diag.Diagnostics) *applications.ApplicationsApiOverview {
+	// There's no availability endpoint for hardware packages, so when the cluster is out of
+	// capacity the create is simply retried every interval
+	var app *applications.ApplicationsApiOverview
+	err := waitForCapacity(ctx, capacityWaitTimeout(reqData.WaitForCapacityTimeout), time.Duration(reqData.Interval.ValueInt64())*time.Second, nil, func() error {
+		var err error
+
+		// The config validators guarantee that exactly one of image_url or
+		// application_catalog_item_name is set, so image_url alone selects the mode.
+		audit := auditEntry{
+			ResourceType: "denvr_app",
+			Resource:     auditResource(reqData.Cluster, reqData.Name),
+			Request:      appAuditRequest(ctx, reqData),
+		}
+		if isCustomApplication(reqData) {
+			audit.Operation = "CreateCustomApplication"
+			app, err = createCustomApplication(ctx, client, reqData)
+		} else {
+			audit.Operation = "CreateCatalogApplication"
+			app, err = createCatalogApplication(ctx, client, reqData)
+		}
+		if app != nil {
+			audit.ResponseID, audit.Status = auditString(app.Id), auditString(app.Status)
+		}
+		r.client.audit(audit, err, diags)
+		return err
+	})
+	if err != nil {
+		diags.AddError("Error creating application", err.Error())
+		return nil
+	} else if app == nil {
+		diags.AddError("Error creating application", "Application was not created and no error was returned.")
+		return nil
+	} else if app.Id == nil {
+		diags.AddError("Error creating application", "Returned application Id is nil")
+		return nil
+	} else if app.Cluster == nil {
+		diags.AddError("Error creating application", "Returned application Cluster is nil")
+		return nil
+	}
+	return app
+}
+
 func (r *appResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	if r.client == nil {
+		resp.Deferred = deferUnconfigured(req.ClientCapabilities.DeferralAllowed, &resp.Diagnostics)
+		return
+	}
+
 	var data appResourceModel
 
 	// Read Terraform prior state data into the model
@@ -296,6 +711,13 @@ func (r *appResource) Read(ctx context.Context, req resource.ReadRequest, resp *
 		return
 	}
 
+	ctx, span := r.client.startSpan(ctx, "denvr_app.Read",
+		attrCluster.String(data.Cluster.ValueString()),
+		attrConfiguration.String(data.HardwarePackageName.ValueString()),
+		attrResourceID.String(data.Id.ValueString()),
+	)
+	defer func() { r.client.endOperation(ctx, span, resp.Diagnostics) }()
+
 	// Read API call logic
 	getParams := applications.GetApplicationDetailsParams{
 		Id:      data.Id.ValueString(),
@@ -303,7 +725,7 @@ func (r *appResource) Read(ctx context.Context, req resource.ReadRequest, resp *
 	}
 
 	tflog.Debug(ctx, "Constructing application service client")
-	client := applications.NewClient()
+	client := r.client.applications()
 
 	tflog.Debug(ctx, "Making applications get request")
 	details, err := client.GetApplicationDetails(ctx, &getParams)
@@ -316,6 +738,10 @@ func (r *appResource) Read(ctx context.Context, req resource.ReadRequest, resp *
 		return
 	}
 
+	if details.InstanceDetails.Status != nil {
+		span.SetAttributes(attrStatus.String(*details.InstanceDetails.Status))
+	}
+
 	tflog.Debug(ctx, "Updating application resource state")
 	data = updateState(ctx, data, *details.InstanceDetails)
 
@@ -325,6 +751,10 @@ func (r *appResource) Read(ctx context.Context, req resource.ReadRequest, resp *
 }
 
 func (r *appResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	if r.client.checkReadOnly("Updating an application", &resp.Diagnostics) {
+		return
+	}
+
 	var data appResourceModel
 
 	// Read Terraform plan data into the model
@@ -341,6 +771,10 @@ func (r *appResource) Update(ctx context.Context, req resource.UpdateRequest, re
 }
 
 func (r *appResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	if r.client.checkReadOnly("Deleting an application", &resp.Diagnostics) {
+		return
+	}
+
 	tflog.Debug(ctx, "Reading Terraform plan data into appResourceModel")
 	var data appResourceModel
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
@@ -348,6 +782,17 @@ func (r *appResource) Delete(ctx context.Context, req resource.DeleteRequest, re
 		return
 	}
 
+	if checkDeleteProtection("application", data.Id.ValueString(), data.DeletionProtection, &resp.Diagnostics) {
+		return
+	}
+
+	ctx, span := r.client.startSpan(ctx, "denvr_app.Delete",
+		attrCluster.String(data.Cluster.ValueString()),
+		attrConfiguration.String(data.HardwarePackageName.ValueString()),
+		attrResourceID.String(data.Id.ValueString()),
+	)
+	defer func() { r.client.endOperation(ctx, span, resp.Diagnostics) }()
+
 	tflog.Debug(ctx, "Constructing application deletion request")
 	destroyParams := applications.DestroyApplicationParams{
 		Id:      data.Id.ValueString(),
@@ -355,10 +800,20 @@ func (r *appResource) Delete(ctx context.Context, req resource.DeleteRequest, re
 	}
 
 	tflog.Debug(ctx, "Constructing application service client")
-	client := applications.NewClient()
+	client := r.client.applications()
 
 	tflog.Debug(ctx, "Making application deletion request")
 	app, err := client.DestroyApplication(ctx, &destroyParams)
+	audit := auditEntry{
+		Operation:    "DestroyApplication",
+		ResourceType: "denvr_app",
+		Resource:     auditResource(data.Cluster, data.Name),
+		Request:      appAuditRequest(ctx, data),
+	}
+	if app != nil {
+		audit.ResponseID = auditString(app.Id)
+	}
+	r.client.audit(audit, err, &resp.Diagnostics)
 	if err != nil {
 		if strings.Contains(err.Error(), fmt.Sprintf("\"%s\" not found", destroyParams.Id)) {
 			resp.State.RemoveResource(ctx)
@@ -367,18 +822,82 @@ func (r *appResource) Delete(ctx context.Context, req resource.DeleteRequest, re
 		}
 		return
 	}
+}
 
-	tflog.Debug(ctx, "Updating application resource state")
-	appJson, err := json.MarshalIndent(app, "", "\t")
-	if err != nil {
-		resp.Diagnostics.AddError("Error marshaling application response", err.Error())
-		return
+// requestModel returns a copy of the model with the secrets which are never saved to state filled in.
+// Write-only attributes are always null in the plan, so we read them from the config, and
+// registry credentials are resolved from the docker config when requested.
+func requestModel(ctx context.Context, config tfsdk.Config, data appResourceModel) (appResourceModel, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	reqData := data
+
+	diags.Append(config.GetAttribute(ctx, path.Root("image_repository_password_wo"), &reqData.ImageRepositoryPasswordWo)...)
+	diags.Append(config.GetAttribute(ctx, path.Root("jupyter_token_wo"), &reqData.JupyterTokenWo)...)
+	if diags.HasError() {
+		return reqData, diags
+	}
+	if !reqData.ImageRepositoryPasswordWo.IsNull() {
+		reqData.ImageRepositoryPassword = reqData.ImageRepositoryPasswordWo
+	}
+	if !reqData.JupyterTokenWo.IsNull() {
+		reqData.JupyterToken = reqData.JupyterTokenWo
 	}
-	tflog.Debug(ctx, string(appJson))
+
+	if reqData.ImageRepositoryAuth.ValueString() == imageRepositoryAuthDockerConfig {
+		host := imageRegistryHost(reqData.ImageUrl.ValueString())
+		tflog.Debug(ctx, "Resolving image repository credentials from docker config for "+host)
+		creds, err := resolveDockerCredentials(ctx, host)
+		if err != nil {
+			diags.AddAttributeError(path.Root("image_repository_auth"), "Error resolving image repository credentials", err.Error())
+			return reqData, diags
+		}
+
+		reqData.ImageRepositoryUsername = types.StringValue(creds.Username)
+		reqData.ImageRepositoryPassword = types.StringValue(creds.Password)
+	}
+
+	return reqData, diags
+}
+
+// appAuditRequest summarises an application for the audit log. Only the names of environment
+// variables are included, and none of the registry credentials or Jupyter token.
+func appAuditRequest(ctx context.Context, data appResourceModel) map[string]interface{} {
+	summary := map[string]interface{}{
+		"cluster":               data.Cluster.ValueString(),
+		"hardware_package_name": data.HardwarePackageName.ValueString(),
+		"id":                    data.Id.ValueString(),
+		"name":                  data.Name.ValueString(),
+		"resource_pool":         data.ResourcePool.ValueString(),
+	}
+	if isCustomApplication(data) {
+		summary["image_url"] = data.ImageUrl.ValueString()
+
+		var names []string
+		for _, env := range []types.Map{data.EnvironmentVariables, data.SensitiveEnvironmentVariables} {
+			var values map[string]types.String
+			env.ElementsAs(ctx, &values, false)
+			for name := range values {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		summary["environment_variable_names"] = names
+	} else {
+		summary["application_catalog_item_name"] = data.ApplicationCatalogItemName.ValueString()
+		summary["application_catalog_item_version"] = data.ApplicationCatalogItemVersion.ValueString()
+	}
+	return summary
+}
+
+// isCustomApplication returns true if the model describes a custom container image
+// rather than an application catalog item.
+func isCustomApplication(data appResourceModel) bool {
+	return data.ImageUrl.ValueString() != ""
 }
 
 func createCatalogApplication(ctx context.Context, client applications.Client, data appResourceModel) (*applications.ApplicationsApiOverview, error) {
 	tflog.Debug(ctx, "Constructing catalog application request")
+	ctx = withAPILogSecrets(ctx, data.JupyterToken.ValueString())
 
 	// Convert SSH keys
 	var sshKeys []string
@@ -388,10 +907,16 @@ func createCatalogApplication(ctx context.Context, client applications.Client, d
 		}
 	}
 
+	// Prefer the version resolved during plan in case we were given "latest" or a constraint
+	catalogVersion := data.ApplicationCatalogItemVersion.ValueString()
+	if data.ResolvedCatalogVersion.ValueString() != "" {
+		catalogVersion = data.ResolvedCatalogVersion.ValueString()
+	}
+
 	// Construct the request body
 	appReq := applications.CreateCatalogApplicationJSONRequestBody{
 		ApplicationCatalogItemName:    data.ApplicationCatalogItemName.ValueString(),
-		ApplicationCatalogItemVersion: data.ApplicationCatalogItemVersion.ValueString(),
+		ApplicationCatalogItemVersion: catalogVersion,
 		Cluster:                       data.Cluster.ValueString(),
 		HardwarePackageName:           data.HardwarePackageName.ValueString(),
 		JupyterToken:                  data.JupyterToken.ValueStringPointer(),
@@ -423,6 +948,24 @@ func createCustomApplication(ctx context.Context, client applications.Client, da
 		}
 	}
 
+	// Merge in sensitive environment variables, duplicate keys are rejected by ValidateConfig
+	var sensitiveEnvVars map[string]*string
+	if !data.SensitiveEnvironmentVariables.IsNull() {
+		if diags := data.SensitiveEnvironmentVariables.ElementsAs(ctx, &sensitiveEnvVars, false); diags.HasError() {
+			return nil, fmt.Errorf("error parsing sensitive environment variables: %v", diags)
+		}
+	}
+	if len(sensitiveEnvVars) > 0 && envVars == nil {
+		envVars = make(map[string]*string, len(sensitiveEnvVars))
+	}
+	for key, value := range sensitiveEnvVars {
+		envVars[key] = value
+		if value != nil {
+			ctx = withAPILogSecrets(ctx, *value)
+		}
+	}
+	ctx = withAPILogSecrets(ctx, data.ImageRepositoryUsername.ValueString(), data.ImageRepositoryPassword.ValueString())
+
 	// Convert image command override
 	var imageCmdOverride []string
 	if !data.ImageCmdOverride.IsNull() {
@@ -431,6 +974,37 @@ func createCustomApplication(ctx context.Context, client applications.Client, da
 		}
 	}
 
+	// Convert SSH keys
+	var sshKeys []string
+	if !data.SshKeys.IsNull() {
+		if diags := data.SshKeys.ElementsAs(ctx, &sshKeys, false); diags.HasError() {
+			return nil, fmt.Errorf("error parsing SSH keys: %v", diags)
+		}
+	}
+
+	// Only send a security context if one of its fields was configured,
+	// otherwise let the API apply the image defaults.
+	var securityContext *applications.SecurityContextDto
+	if !data.SecurityContextRunAsRoot.IsNull() ||
+		!data.SecurityContextContainerUid.IsNull() ||
+		!data.SecurityContextContainerGid.IsNull() {
+		securityContext = &applications.SecurityContextDto{
+			RunAsRoot:    data.SecurityContextRunAsRoot.ValueBoolPointer(),
+			ContainerUid: data.SecurityContextContainerUid.ValueInt32Pointer(),
+			ContainerGid: data.SecurityContextContainerGid.ValueInt32Pointer(),
+		}
+	}
+
+	// Pin the image to the digest resolved during plan
+	imageUrl := data.ImageUrl.ValueString()
+	if data.ImageDigest.ValueString() != "" {
+		ref, err := parseImageReference(imageUrl)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing image url: %w", err)
+		}
+		imageUrl = ref.WithDigest(data.ImageDigest.ValueString())
+	}
+
 	// Construct the request body
 	appReq := applications.CreateCustomApplicationJSONRequestBody{
 		Cluster:              data.Cluster.ValueString(),
@@ -442,13 +1016,15 @@ func createCustomApplication(ctx context.Context, client applications.Client, da
 			Username: data.ImageRepositoryUsername.ValueStringPointer(),
 			Password: data.ImageRepositoryPassword.ValueStringPointer(),
 		},
-		ImageUrl:                     data.ImageUrl.ValueString(),
+		ImageUrl:                     imageUrl,
 		Name:                         data.Name.ValueString(),
 		PersistDirectAttachedStorage: data.PersistDirectAttachedStorage.ValueBoolPointer(),
 		PersonalSharedStorage:        data.PersonalSharedStorage.ValueBoolPointer(),
 		ProxyPort:                    data.ProxyPort.ValueInt32Pointer(),
 		ReadinessWatcherPort:         data.ReadinessWatcherPort.ValueInt32Pointer(),
 		ResourcePool:                 data.ResourcePool.ValueStringPointer(),
+		SecurityContext:              securityContext,
+		SshKeys:                      &sshKeys,
 		TenantSharedStorage:          data.TenantSharedStorage.ValueBoolPointer(),
 	}
 
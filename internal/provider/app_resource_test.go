@@ -1,16 +1,20 @@
 package provider
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
 	"testing"
 
 	"github.com/denvrdata/go-denvr/result"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
 )
 
 // TestCatalogApp is a test app resource model for catalog based applications.
@@ -231,6 +235,27 @@ resource "denvr_app" "test_custom" {
 	TestCustomApp.Timeout.ValueInt64(),
 )
 
+var customAppWriteOnlyResourceConfig = fmt.Sprintf(`
+resource "denvr_app" "test_custom" {
+ name = "%s"
+ cluster = "%s"
+ hardware_package_name = "%s"
+ image_repository_username = "terraform"
+ image_repository_password_wo = "hunter2"
+ image_repository_password_wo_version = 1
+ image_url = "%s"
+ resource_pool = "%s"
+ security_context_run_as_root = %t
+}
+`,
+	TestCustomApp.Name.ValueString(),
+	TestCustomApp.Cluster.ValueString(),
+	TestCustomApp.HardwarePackageName.ValueString(),
+	TestCustomApp.ImageUrl.ValueString(),
+	TestCustomApp.ResourcePool.ValueString(),
+	TestCustomApp.SecurityContextRunAsRoot.ValueBool(),
+)
+
 func TestAccAppResource_basic(t *testing.T) {
 	mux := http.NewServeMux()
 	mux.HandleFunc(
@@ -249,6 +274,7 @@ func TestAccAppResource_basic(t *testing.T) {
 		},
 	)
 	catalogStatus := "UNKNOWN"
+	customPassword := ""
 	mux.HandleFunc(
 		"/api/v1/servers/applications/CreateCatalogApplication",
 		func(resp http.ResponseWriter, req *http.Request) {
@@ -260,6 +286,24 @@ func TestAccAppResource_basic(t *testing.T) {
 	mux.HandleFunc(
 		"/api/v1/servers/applications/CreateCustomApplication",
 		func(resp http.ResponseWriter, req *http.Request) {
+			// Make sure the security context in our config actually reaches the API
+			var body struct {
+				ImageRepository struct {
+					Password *string `json:"password"`
+				} `json:"imageRepository"`
+				SecurityContext *struct {
+					RunAsRoot *bool `json:"runAsRoot"`
+				} `json:"securityContext"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil ||
+				body.SecurityContext == nil || body.SecurityContext.RunAsRoot == nil {
+				resp.WriteHeader(http.StatusBadRequest)
+				resp.Write([]byte(`{"error": {"message": "Missing securityContext.runAsRoot"}}`))
+				return
+			}
+			if body.ImageRepository.Password != nil {
+				customPassword = *body.ImageRepository.Password
+			}
 			catalogStatus = "UNKNOWN"
 			resp.WriteHeader(http.StatusOK)
 			resp.Write([]byte(makeApplicationsApiOverviewResponse(TestCatalogApp, catalogStatus)))
@@ -356,4 +400,136 @@ func TestAccAppResource_basic(t *testing.T) {
 				},
 			},
 		})
+
+	resource.Test(
+		t, resource.TestCase{
+			ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+			TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+				tfversion.SkipBelow(tfversion.Version1_11_0),
+			},
+			Steps: []resource.TestStep{
+				{
+					Config: providerConfig + customAppWriteOnlyResourceConfig,
+					Check: resource.ComposeTestCheckFunc(
+						// The password should reach the API without being stored in state
+						resource.TestCheckNoResourceAttr("denvr_app.test_custom", "image_repository_password"),
+						resource.TestCheckNoResourceAttr("denvr_app.test_custom", "image_repository_password_wo"),
+						resource.TestCheckResourceAttr("denvr_app.test_custom", "image_repository_password_wo_version", "1"),
+						// The hostname should default to the registry of the image_url
+						resource.TestCheckResourceAttr("denvr_app.test_custom", "image_repository_hostname", "https://index.docker.io/v1/"),
+						func(s *terraform.State) error {
+							if customPassword != "hunter2" {
+								return fmt.Errorf("expected image repository password %q, got %q", "hunter2", customPassword)
+							}
+							return nil
+						},
+					),
+				},
+			},
+		})
+}
+
+func TestAccAppResource_validation(t *testing.T) {
+	resource.Test(
+		t, resource.TestCase{
+			ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+			Steps: []resource.TestStep{
+				{
+					// Neither a catalog item nor a custom image
+					Config: providerConfig + `
+resource "denvr_app" "test" {
+ name = "terraform-app"
+ cluster = "Msc1"
+ hardware_package_name = "g-nvidia-1xa100-40gb-pcie-14vcpu-112gb"
+ resource_pool = "on-demand"
+}
+`,
+					ExpectError: regexp.MustCompile(`Missing Attribute Configuration`),
+				},
+				{
+					// Both a catalog item and a custom image
+					Config: providerConfig + `
+resource "denvr_app" "test" {
+ name = "terraform-app"
+ cluster = "Msc1"
+ hardware_package_name = "g-nvidia-1xa100-40gb-pcie-14vcpu-112gb"
+ resource_pool = "on-demand"
+ application_catalog_item_name = "jupyter-notebook"
+ image_url = "karthequian/helloworld:latest"
+}
+`,
+					ExpectError: regexp.MustCompile(`Invalid Attribute Combination`),
+				},
+				{
+					// Custom only field on a catalog application
+					Config: providerConfig + `
+resource "denvr_app" "test" {
+ name = "terraform-app"
+ cluster = "Msc1"
+ hardware_package_name = "g-nvidia-1xa100-40gb-pcie-14vcpu-112gb"
+ resource_pool = "on-demand"
+ application_catalog_item_name = "jupyter-notebook"
+ proxy_port = 80
+}
+`,
+					ExpectError: regexp.MustCompile(`"application_catalog_item_name"\s+cannot\s+be\s+specified\s+when\s+"proxy_port"`),
+				},
+				{
+					// Catalog only field on a custom application
+					Config: providerConfig + `
+resource "denvr_app" "test" {
+ name = "terraform-app"
+ cluster = "Msc1"
+ hardware_package_name = "g-nvidia-1xa100-40gb-pcie-14vcpu-112gb"
+ resource_pool = "on-demand"
+ image_url = "karthequian/helloworld:latest"
+ jupyter_token = "abc123"
+}
+`,
+					ExpectError: regexp.MustCompile(`"image_url"\s+cannot\s+be\s+specified\s+when\s+"jupyter_token"`),
+				},
+				{
+					// Same environment variable in both the plain and sensitive maps
+					Config: providerConfig + `
+resource "denvr_app" "test" {
+ name = "terraform-app"
+ cluster = "Msc1"
+ hardware_package_name = "g-nvidia-1xa100-40gb-pcie-14vcpu-112gb"
+ resource_pool = "on-demand"
+ image_url = "karthequian/helloworld:latest"
+ environment_variables = { API_KEY = "foo" }
+ sensitive_environment_variables = { API_KEY = "bar" }
+}
+`,
+					ExpectError: regexp.MustCompile(`Duplicate environment variable`),
+				},
+				{
+					// Adopting a custom application, whose image can't be compared
+					Config: providerConfig + `
+resource "denvr_app" "test" {
+ name = "terraform-app"
+ cluster = "Msc1"
+ hardware_package_name = "g-nvidia-1xa100-40gb-pcie-14vcpu-112gb"
+ resource_pool = "on-demand"
+ image_url = "karthequian/helloworld:latest"
+ adopt_existing = true
+}
+`,
+					ExpectError: regexp.MustCompile(`adopt_existing\s+can't\s+be\s+set`),
+				},
+				{
+					// Malformed image reference
+					Config: providerConfig + `
+resource "denvr_app" "test" {
+ name = "terraform-app"
+ cluster = "Msc1"
+ hardware_package_name = "g-nvidia-1xa100-40gb-pcie-14vcpu-112gb"
+ resource_pool = "on-demand"
+ image_url = "ghcr.io/Denvr/helloworld:latest"
+}
+`,
+					ExpectError: regexp.MustCompile(`Invalid Image Reference`),
+				},
+			},
+		})
 }
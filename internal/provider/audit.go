@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// auditEntry is a line of the audit log. Request holds a summary of the request which never
+// includes secrets like passwords, tokens or environment variable values.
+//
+// Terraform doesn't give providers the address of a resource, e.g. denvr_vm.foo[3], so Resource
+// identifies it by "<cluster>/<name>" instead, which together with ResourceType is unique.
+type auditEntry struct {
+	Timestamp    time.Time              `json:"timestamp"`
+	User         string                 `json:"user"`
+	Operation    string                 `json:"operation"`
+	ResourceType string                 `json:"resource_type"`
+	Resource     string                 `json:"resource"`
+	Request      map[string]interface{} `json:"request"`
+	ResponseID   string                 `json:"response_id,omitempty"`
+	Status       string                 `json:"status,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+// auditLogger appends a JSON line to a file for every mutating Denvr API call.
+type auditLogger struct {
+	path string
+	user string
+
+	// now is replaced in tests
+	now func() time.Time
+
+	mu sync.Mutex
+}
+
+// newAuditLogger checks that the audit log can be written to, creating it if needed.
+func newAuditLogger(path, user string) (*auditLogger, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	return &auditLogger{path: path, user: user, now: time.Now}, nil
+}
+
+// record appends the entry as a single write, so concurrent Terraform runs sharing a log
+// don't interleave their lines. It does nothing on a nil logger.
+func (a *auditLogger) record(entry auditEntry) error {
+	if a == nil {
+		return nil
+	}
+
+	entry.Timestamp = a.now().UTC()
+	entry.User = a.user
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	file, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// audit records a mutating API call, with err being the error it returned. The call has already
+// been made, so a failure to write the audit log is a warning rather than an error.
+func (c *denvrClient) audit(entry auditEntry, err error, diags *diag.Diagnostics) {
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if auditErr := c.auditLog.record(entry); auditErr != nil {
+		diags.AddWarning(
+			"Unable to Write Denvr Audit Log",
+			fmt.Sprintf("The %s call was not recorded in the audit log: %s", entry.Operation, auditErr),
+		)
+	}
+}
+
+// auditResource identifies a resource in the audit log by its cluster and name.
+func auditResource(cluster, name types.String) string {
+	return cluster.ValueString() + "/" + name.ValueString()
+}
+
+// auditString dereferences an optional API response field.
+func auditString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestAuditLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := os.WriteFile(path, []byte(`{"operation":"existing"}`+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	auditLog, err := newAuditLogger(path, "test@foobar.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditLog.now = func() time.Time { return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC) }
+	client := &denvrClient{auditLog: auditLog}
+
+	var diags diag.Diagnostics
+	client.audit(auditEntry{
+		Operation:    "CreateServer",
+		ResourceType: "denvr_vm",
+		Resource:     auditResource(types.StringValue("Msc1"), types.StringValue("my-vm")),
+		Request:      map[string]interface{}{"cluster": "Msc1"},
+		ResponseID:   "my-vm",
+		Status:       "PENDING",
+	}, nil, &diags)
+	client.audit(auditEntry{Operation: "DestroyServer", ResourceType: "denvr_vm"}, errors.New("not found"), &diags)
+	if diags.HasError() || diags.WarningsCount() > 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid audit log line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) != 3 || lines[0]["operation"] != "existing" {
+		t.Fatalf("expected two entries appended to the existing log, got %v", lines)
+	}
+
+	created := lines[1]
+	for key, want := range map[string]interface{}{
+		"timestamp":     "2025-03-01T12:00:00Z",
+		"user":          "test@foobar.com",
+		"operation":     "CreateServer",
+		"resource_type": "denvr_vm",
+		"resource":      "Msc1/my-vm",
+		"response_id":   "my-vm",
+		"status":        "PENDING",
+	} {
+		if created[key] != want {
+			t.Errorf("expected %s to be %v, got %v", key, want, created[key])
+		}
+	}
+	if _, ok := created["error"]; ok {
+		t.Errorf("expected no error on a successful call, got %v", created["error"])
+	}
+	if lines[2]["error"] != "not found" {
+		t.Errorf("expected the failed call's error to be recorded, got %v", lines[2])
+	}
+}
+
+func TestAuditLogger_unwritable(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := newAuditLogger(filepath.Join(dir, "missing", "audit.jsonl"), "test@foobar.com"); err == nil {
+		t.Error("expected an error for an audit log in a missing directory")
+	}
+
+	// A logger which can no longer write warns rather than failing the operation
+	client := &denvrClient{auditLog: &auditLogger{path: dir, now: time.Now}}
+	var diags diag.Diagnostics
+	client.audit(auditEntry{Operation: "CreateServer"}, nil, &diags)
+	if diags.HasError() || diags.WarningsCount() != 1 {
+		t.Errorf("expected a single warning, got %v", diags)
+	}
+
+	// Auditing is a no-op without audit_log_file
+	diags = nil
+	(&denvrClient{}).audit(auditEntry{Operation: "CreateServer"}, nil, &diags)
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics without an audit log, got %v", diags)
+	}
+}
+
+func TestAppAuditRequest(t *testing.T) {
+	data := appResourceModel{
+		Cluster:             types.StringValue("Msc1"),
+		HardwarePackageName: types.StringValue("g-nvidia-1xa100-40gb-pcie-14vcpu-112gb"),
+		Name:                types.StringValue("my-app"),
+		ImageUrl:            types.StringValue("registry.example.com/team/app:1.0"),
+		EnvironmentVariables: types.MapValueMust(types.StringType, map[string]attr.Value{
+			"LOG_LEVEL": types.StringValue("debug"),
+		}),
+		SensitiveEnvironmentVariables: types.MapValueMust(types.StringType, map[string]attr.Value{
+			"API_KEY": types.StringValue("s3cr3t-env-value"),
+		}),
+		ImageRepositoryUsername: types.StringValue("robot"),
+		ImageRepositoryPassword: types.StringValue("hunter2"),
+		JupyterToken:            types.StringValue("abc123"),
+	}
+
+	summary := appAuditRequest(context.Background(), data)
+	content, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{"s3cr3t-env-value", "hunter2", "abc123", "debug"} {
+		if strings.Contains(string(content), secret) {
+			t.Errorf("expected %q to be left out of the audit summary, got %s", secret, content)
+		}
+	}
+	for _, want := range []string{`"environment_variable_names":["API_KEY","LOG_LEVEL"]`, `"image_url":"registry.example.com/team/app:1.0"`, `"cluster":"Msc1"`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected the audit summary to contain %s, got %s", want, content)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// denvrAuthRefreshMargin renews the access token this long before it expires so requests don't race the expiry.
+const denvrAuthRefreshMargin = 30 * time.Second
+
+// denvrAuthPath is the endpoint which exchanges a username and password for an access token.
+const denvrAuthPath = "/api/TokenAuth/Authenticate"
+
+var (
+	// errDenvrUnauthorized is returned when the API rejects the username or password.
+	errDenvrUnauthorized = errors.New("invalid username or password")
+
+	// errDenvrUnexpectedAPI is returned when the server doesn't respond like the Denvr v1 API,
+	// usually because the server URL points somewhere else (e.g. the console instead of the API).
+	errDenvrUnexpectedAPI = errors.New("unexpected API response")
+)
+
+// denvrAuth logs in to the Denvr API with a username and password and
+// adds the resulting bearer token to each request.
+type denvrAuth struct {
+	server     string
+	username   string
+	password   string
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newDenvrAuth(httpClient *http.Client, creds denvrCredentials) *denvrAuth {
+	return &denvrAuth{
+		server:     creds.Server,
+		username:   creds.Username,
+		password:   creds.Password,
+		httpClient: httpClient,
+	}
+}
+
+// Intercept is a go-denvr RequestEditorFn which sets the Authorization header.
+func (a *denvrAuth) Intercept(ctx context.Context, req *http.Request) error {
+	token, err := a.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// accessToken returns the cached access token, logging in again if it has expired.
+func (a *denvrAuth) accessToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Now().Before(a.expires) {
+		return a.token, nil
+	}
+
+	tflog.Debug(ctx, "Authenticating with "+a.server)
+	body, err := json.Marshal(map[string]string{
+		"userNameOrEmailAddress": a.username,
+		"password":               a.password,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.server+denvrAuthPath, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error authenticating with %s: %w", a.server, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Result struct {
+			AccessToken     string `json:"accessToken"`
+			ExpireInSeconds int64  `json:"expireInSeconds"`
+		} `json:"result"`
+		Error *denvrAPIError `json:"error"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&result)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%w: %s returned %s for the authentication endpoint", errDenvrUnexpectedAPI, a.server, resp.Status)
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", fmt.Errorf("%w: %s", errDenvrUnauthorized, result.Error.format(resp.Status))
+	case decodeErr != nil:
+		return "", fmt.Errorf("%w: unable to parse authentication response from %s (%s): %s", errDenvrUnexpectedAPI, a.server, resp.Status, decodeErr)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("authentication with %s failed: %s", a.server, result.Error.format(resp.Status))
+	case result.Result.AccessToken == "":
+		return "", fmt.Errorf("%w: authentication with %s returned no access token", errDenvrUnexpectedAPI, a.server)
+	}
+
+	a.token = result.Result.AccessToken
+	a.expires = time.Now().Add(time.Duration(result.Result.ExpireInSeconds)*time.Second - denvrAuthRefreshMargin)
+	return a.token, nil
+}
+
+// denvrAPIError is the error object of a failed Denvr API response.
+type denvrAPIError struct {
+	Message string `json:"message"`
+	Details string `json:"details"`
+}
+
+// format appends the API error message, if any, to the response status.
+func (e *denvrAPIError) format(status string) string {
+	if e == nil || e.Message == "" {
+		return status
+	}
+	if e.Details != "" {
+		return fmt.Sprintf("%s: %s (%s)", status, e.Message, e.Details)
+	}
+	return fmt.Sprintf("%s: %s", status, e.Message)
+}
+
+// describeAuthError turns an authentication failure into a diagnostic summary and detail
+// which say whether the credentials, the network, TLS or the server URL is at fault.
+func describeAuthError(server string, err error) (string, string) {
+	var (
+		dnsErr       *net.DNSError
+		opErr        *net.OpError
+		unknownCAErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		certErr      x509.CertificateInvalidError
+		verifyErr    *tls.CertificateVerificationError
+		recordErr    tls.RecordHeaderError
+		urlErr       *url.Error
+		summary      string
+		remedy       string
+	)
+
+	switch {
+	case errors.Is(err, errDenvrUnauthorized):
+		summary = "Invalid Denvr Credentials"
+		remedy = "Check the username and password, or the source they were resolved from."
+	case errors.Is(err, errDenvrUnexpectedAPI):
+		summary = "Unexpected Denvr API Version"
+		remedy = "Check that the server is the URL of the Denvr Cloud API (e.g. " + defaultServer + ")."
+	case errors.As(err, &unknownCAErr), errors.As(err, &hostnameErr), errors.As(err, &certErr),
+		errors.As(err, &verifyErr), errors.As(err, &recordErr):
+		summary = "TLS Error Connecting to Denvr API"
+		remedy = "Check the server URL scheme and that the server certificate is trusted, e.g. with ca_bundle_file."
+	case errors.As(err, &dnsErr), errors.As(err, &opErr), errors.As(err, &urlErr) && urlErr.Timeout():
+		summary = "Unable to Reach Denvr API"
+		remedy = "Check the server URL and your network connection."
+	default:
+		summary = "Unable to Authenticate with Denvr API"
+	}
+
+	detail := fmt.Sprintf("Authenticating with %s failed: %s", server, err)
+	if remedy != "" {
+		detail += "\n\n" + remedy
+	}
+	detail += "\n\nSet skip_credentials_validation to skip this check."
+	return summary, detail
+}
@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDenvrAuth(t *testing.T) {
+	logins := 0
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		var body struct {
+			UserNameOrEmailAddress string `json:"userNameOrEmailAddress"`
+			Password               string `json:"password"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil || req.URL.Path != "/api/TokenAuth/Authenticate" {
+			resp.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if body.UserNameOrEmailAddress != "test@foobar.com" || body.Password != "test.foo.bar.baz" {
+			resp.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		logins++
+		fmt.Fprintf(resp, `{"result": {"accessToken": "token-%d", "expireInSeconds": 3600}}`, logins)
+	}))
+	defer server.Close()
+
+	auth := newDenvrAuth(server.Client(), denvrCredentials{Server: server.URL, Username: "test@foobar.com", Password: "test.foo.bar.baz"})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := auth.Intercept(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer token-1" {
+			t.Errorf("expected cached token, got %q", got)
+		}
+	}
+
+	// Expired tokens are renewed on the next request
+	auth.expires = auth.expires.Add(-2 * time.Hour)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := auth.Intercept(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer token-2" {
+		t.Errorf("expected renewed token, got %q", got)
+	}
+
+	auth = newDenvrAuth(server.Client(), denvrCredentials{Server: server.URL, Username: "test@foobar.com", Password: "wrong"})
+	if err := auth.Intercept(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Error("expected authentication to fail")
+	}
+}
+
+func TestDescribeAuthError(t *testing.T) {
+	creds := denvrCredentials{Username: "test@foobar.com", Password: "test.foo.bar.baz"}
+
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, _ *http.Request) {
+		resp.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(resp, `{"error": {"message": "Login failed!", "details": "Invalid user name or password"}}`)
+	}))
+	defer unauthorized.Close()
+
+	console := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(resp, "<html>Denvr Cloud Console</html>")
+	}))
+	defer console.Close()
+
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+
+	selfSigned := httptest.NewTLSServer(http.NotFoundHandler())
+	defer selfSigned.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	cases := []struct {
+		name    string
+		server  string
+		summary string
+		detail  string
+	}{
+		{"unauthorized", unauthorized.URL, "Invalid Denvr Credentials", "Login failed! (Invalid user name or password)"},
+		{"not json", console.URL, "Unexpected Denvr API Version", "unable to parse authentication response"},
+		{"not found", notFound.URL, "Unexpected Denvr API Version", "404 Not Found"},
+		{"tls", selfSigned.URL, "TLS Error Connecting to Denvr API", "certificate"},
+		{"unreachable", closed.URL, "Unable to Reach Denvr API", "connection refused"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			creds.Server = c.server
+			_, err := newDenvrAuth(&http.Client{}, creds).accessToken(context.Background())
+			if err == nil {
+				t.Fatal("expected authentication to fail")
+			}
+
+			summary, detail := describeAuthError(c.server, err)
+			if summary != c.summary {
+				t.Errorf("expected summary %q, got %q (%s)", c.summary, summary, detail)
+			}
+			if !strings.Contains(detail, c.detail) {
+				t.Errorf("expected detail to contain %q, got %q", c.detail, detail)
+			}
+		})
+	}
+}
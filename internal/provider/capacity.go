@@ -0,0 +1,140 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// denvrAvailabilityPath reports how many VMs of each configuration can be created in a cluster.
+const denvrAvailabilityPath = "/api/v1/servers/virtual/GetAvailability"
+
+// capacityPool identifies a cluster's resource pool, which VMs and applications draw their GPUs from.
+type capacityPool struct {
+	Cluster      string
+	ResourcePool string
+}
+
+// capacityTracker adds up the GPUs planned by every resource in a plan, which Terraform plans one at a
+// time in the same provider process, and compares them with the capacity reported by the API.
+type capacityTracker struct {
+	mu sync.Mutex
+
+	// available is the number of free GPUs, looked up once per cluster and pool
+	available map[capacityPool]int
+	lookedUp  map[capacityPool]error
+
+	// planned holds the GPUs of each resource planned for creation by resource type and name,
+	// so planning a resource twice counts it once
+	planned map[capacityPool]map[string]int
+}
+
+func newCapacityTracker() *capacityTracker {
+	return &capacityTracker{
+		available: map[capacityPool]int{},
+		lookedUp:  map[capacityPool]error{},
+		planned:   map[capacityPool]map[string]int{},
+	}
+}
+
+// checkCapacity adds the GPUs of a planned VM or application to the demand for its cluster and
+// resource pool, and reports a warning, or an error if strict_capacity_check is set, once the
+// demand exceeds the free GPUs.
+func (c *denvrClient) checkCapacity(ctx context.Context, pool capacityPool, resourceType, name string, gpus int, diags *diag.Diagnostics) {
+	t := c.capacity
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.planned[pool] == nil {
+		t.planned[pool] = map[string]int{}
+	}
+	t.planned[pool][resourceType+"/"+name] = gpus
+	planned := 0
+	for _, g := range t.planned[pool] {
+		planned += g
+	}
+
+	// Each cluster and pool is looked up once, holding the lock so concurrent plans don't repeat it
+	if _, ok := t.lookedUp[pool]; !ok {
+		available, err := c.availability(ctx, pool.Cluster, pool.ResourcePool)
+		t.available[pool] = availableGPUs(available)
+		t.lookedUp[pool] = err
+	}
+
+	if err := t.lookedUp[pool]; err != nil {
+		diags.AddWarning(
+			"Unable to Check Denvr Capacity",
+			fmt.Sprintf("The available capacity of cluster %s resource pool %s could not be checked before apply: %s", pool.Cluster, pool.ResourcePool, err),
+		)
+		return
+	}
+
+	available := t.available[pool]
+	tflog.Debug(ctx, fmt.Sprintf("Planned %d of %d available GPUs in %s/%s", planned, available, pool.Cluster, pool.ResourcePool))
+	if planned <= available {
+		return
+	}
+
+	summary := "Insufficient Denvr Capacity"
+	detail := fmt.Sprintf("This plan creates VMs and applications with %d GPUs in cluster %s resource pool %s, but only %d GPUs are available. "+
+		"The apply may fail partway through, leaving some of them created.",
+		planned, pool.Cluster, pool.ResourcePool, available)
+	if c.strictCapacityCheck {
+		diags.AddError(summary, detail+" Remove strict_capacity_check to apply anyway.")
+	} else {
+		diags.AddWarning(summary, detail+" Set strict_capacity_check = true to stop plans like this.")
+	}
+}
+
+// availableGPUs returns the free GPUs of a pool from the number of VMs of each configuration which
+// can be created. The configurations share the pool's GPUs, e.g. one free 8x VM and eight free 1x
+// VMs are usually the same 8 GPUs, so the configuration which can use the most of them is taken
+// rather than their sum.
+func availableGPUs(available map[string]int) int {
+	gpus := 0
+	for configuration, count := range available {
+		gpus = max(gpus, count*gpusOrZero(configuration))
+	}
+	return gpus
+}
+
+// availability returns the number of VMs of each configuration which can be created in a cluster's resource pool.
+func (c *denvrClient) availability(ctx context.Context, cluster, resourcePool string) (map[string]int, error) {
+	result, err := c.getResult(ctx, denvrAvailabilityPath, url.Values{"cluster": {cluster}, "resourcePool": {resourcePool}})
+	if err != nil {
+		return nil, err
+	}
+
+	type configurationAvailability struct {
+		Configuration string `json:"configuration"`
+		ResourcePool  string `json:"resourcePool"`
+		Count         int    `json:"count"`
+		Available     *bool  `json:"available"`
+	}
+	items, err := decodeItems[configurationAvailability](result)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse availability response: %w", err)
+	}
+
+	available := map[string]int{}
+	for _, item := range items {
+		if item.ResourcePool != "" && item.ResourcePool != resourcePool {
+			continue
+		}
+		if item.Available != nil && !*item.Available {
+			continue
+		}
+		available[item.Configuration] += item.Count
+	}
+	return available, nil
+}
+
+// gpusOrZero returns the number of GPUs of a VM configuration, or 0 if it isn't known.
+func gpusOrZero(configuration string) int {
+	gpus, _ := parseGPUCount(configurationGPUsRegexp, configuration)
+	return gpus
+}
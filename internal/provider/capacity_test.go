@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+func TestCheckCapacity(t *testing.T) {
+	var lookups atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case denvrAuthPath:
+			fmt.Fprint(resp, `{"result": {"accessToken": "access1", "expireInSeconds": 600}}`)
+		case denvrAvailabilityPath:
+			lookups.Add(1)
+			if req.Header.Get("Authorization") != "Bearer access1" {
+				resp.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if req.URL.Query().Get("cluster") == "Hou1" {
+				resp.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(resp, `{"error": {"message": "availability is unavailable"}}`)
+				return
+			}
+			fmt.Fprint(resp, `{"result": [
+				{"configuration": "H100_80GB_SXM_8x", "resourcePool": "on-demand", "count": 2, "available": true},
+				{"configuration": "A100_40GB_SXM_1x", "resourcePool": "on-demand", "count": 5, "available": false},
+				{"configuration": "H100_80GB_SXM_8x", "resourcePool": "reserved", "count": 10, "available": true}
+			]}`)
+		}
+	}))
+	defer server.Close()
+
+	msc1 := capacityPool{Cluster: "Msc1", ResourcePool: "on-demand"}
+	spot := capacityPool{Cluster: "Msc1", ResourcePool: "spot"}
+	hou1 := capacityPool{Cluster: "Hou1", ResourcePool: "on-demand"}
+
+	type planned struct {
+		pool         capacityPool
+		resourceType string
+		name         string
+		gpus         int
+	}
+	cases := []struct {
+		name      string
+		strict    bool
+		resources []planned
+		warnings  int
+		errors    int
+		detail    string
+	}{
+		{"within capacity", false, []planned{{msc1, "denvr_vm", "vm-1", 8}, {msc1, "denvr_vm", "vm-2", 8}}, 0, 0, ""},
+		{"planned twice", false, []planned{{msc1, "denvr_vm", "vm-1", 8}, {msc1, "denvr_vm", "vm-1", 8}, {msc1, "denvr_app", "vm-1", 8}}, 0, 0, ""},
+		{"mixed configurations", false, []planned{{msc1, "denvr_vm", "vm-1", 8}, {msc1, "denvr_vm", "vm-2", 8}, {msc1, "denvr_vm", "vm-3", 1}}, 1, 0, "creates VMs and applications with 17 GPUs"},
+		{"applications", false, []planned{{msc1, "denvr_vm", "vm-1", 8}, {msc1, "denvr_app", "app-1", 8}, {msc1, "denvr_app", "app-2", 1}}, 1, 0, "with 17 GPUs in cluster Msc1 resource pool on-demand"},
+		{"strict", true, []planned{{msc1, "denvr_vm", "vm-1", 8}, {msc1, "denvr_vm", "vm-2", 8}, {msc1, "denvr_app", "app-1", 8}}, 0, 1, "only 16 GPUs are available"},
+		{"empty pool", false, []planned{{spot, "denvr_vm", "vm-1", 1}}, 1, 0, "only 0 GPUs are available"},
+		{"lookup failure", true, []planned{{hou1, "denvr_vm", "vm-1", 8}}, 1, 0, "availability is unavailable"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lookups.Store(0)
+			client := newDenvrClient(denvrCredentials{Server: server.URL}, denvrClientOptions{StrictCapacityCheck: c.strict})
+
+			var diags diag.Diagnostics
+			for _, r := range c.resources {
+				client.checkCapacity(context.Background(), r.pool, r.resourceType, r.name, r.gpus, &diags)
+			}
+
+			if diags.WarningsCount() != c.warnings || diags.ErrorsCount() != c.errors {
+				t.Fatalf("expected %d warnings and %d errors, got %v", c.warnings, c.errors, diags)
+			}
+			if c.detail != "" && !strings.Contains(diags[0].Detail(), c.detail) {
+				t.Errorf("expected the diagnostic to contain %q, got %q", c.detail, diags[0].Detail())
+			}
+			if lookups.Load() != 1 {
+				t.Errorf("expected availability to be looked up once, got %d", lookups.Load())
+			}
+		})
+	}
+}
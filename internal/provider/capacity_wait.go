@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// capacityErrorRegexp matches the create errors which mean there is no capacity for the request right now.
+var capacityErrorRegexp = regexp.MustCompile(`(?i)(out of|insufficient|no available|not enough) (capacity|resources|gpus|nodes)|capacity (is )?(exhausted|unavailable)`)
+
+// isCapacityError returns true if a create call failed because the cluster is out of capacity.
+func isCapacityError(err error) bool {
+	return err != nil && capacityErrorRegexp.MatchString(err.Error())
+}
+
+// waitForCapacityTimeoutAttribute is the wait_for_capacity_timeout attribute, which turns a create
+// failing for lack of capacity into a wait for capacity to free up.
+func waitForCapacityTimeoutAttribute(kind string) schema.StringAttribute {
+	return schema.StringAttribute{
+		Optional: true,
+		MarkdownDescription: fmt.Sprintf("How long to keep retrying the create call when the cluster is out of capacity for the %s, "+
+			"as a duration like `30m` or `2h`. Capacity is rechecked every `interval` seconds. By default the create fails straight away.", kind),
+		Validators: []validator.String{durationValidator{}},
+	}
+}
+
+// capacityWaitTimeout returns the configured wait_for_capacity_timeout, or zero if it isn't set.
+// The value has already been checked by durationValidator.
+func capacityWaitTimeout(value types.String) time.Duration {
+	timeout, _ := time.ParseDuration(value.ValueString())
+	return timeout
+}
+
+// waitForCapacity calls create, and while it fails with a capacity error polls available every
+// interval and calls create again once it reports capacity, until timeout has passed. The create
+// is always retried when the timeout is reached. A nil available retries the create every interval instead.
+func waitForCapacity(ctx context.Context, timeout, interval time.Duration, available func(context.Context) (bool, error), create func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := create()
+		if timeout <= 0 || !isCapacityError(err) {
+			return err
+		}
+
+		tflog.Info(ctx, "Waiting for Denvr capacity: "+err.Error())
+		for {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return fmt.Errorf("%w (gave up waiting for capacity after %s)", err, timeout)
+			}
+			if sleepErr := sleepContext(ctx, min(interval, remaining)); sleepErr != nil {
+				return fmt.Errorf("%w (stopped waiting for capacity: %s)", err, sleepErr)
+			}
+
+			// The create is tried once more when the timeout is reached, even if it's sooner than interval
+			if available == nil || !time.Now().Before(deadline) {
+				break
+			}
+
+			// Retry the create when availability can't be checked, rather than waiting out the timeout
+			ok, availErr := available(ctx)
+			if availErr != nil {
+				tflog.Warn(ctx, "Unable to check Denvr capacity: "+availErr.Error())
+			}
+			if ok || availErr != nil {
+				break
+			}
+		}
+	}
+}
+
+var _ validator.String = durationValidator{}
+
+// durationValidator validates that a string is a positive Go duration, e.g. "30m".
+type durationValidator struct{}
+
+func (v durationValidator) Description(ctx context.Context) string {
+	return v.MarkdownDescription(ctx)
+}
+
+func (v durationValidator) MarkdownDescription(_ context.Context) string {
+	return "value must be a positive duration, e.g. 30m"
+}
+
+func (v durationValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	if d, err := time.ParseDuration(req.ConfigValue.ValueString()); err != nil || d <= 0 {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid Duration",
+			fmt.Sprintf("%q is not a positive duration, expected a value like \"30m\" or \"2h\"", req.ConfigValue.ValueString()),
+		)
+	}
+}
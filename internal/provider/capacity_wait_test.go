@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestWaitForCapacity(t *testing.T) {
+	errCapacity := errors.New("create server failed: 400 Bad Request: Insufficient capacity for configuration H100_80GB_SXM_8x")
+	errOther := errors.New("create server failed: 400 Bad Request: invalid vpc")
+
+	cases := []struct {
+		name      string
+		timeout   time.Duration
+		creates   []error
+		available []bool
+		nilCheck  bool
+		wantCalls int
+		wantErr   string
+	}{
+		{"created", time.Second, []error{nil}, nil, false, 1, ""},
+		{"no wait", 0, []error{errCapacity}, nil, false, 1, "Insufficient capacity"},
+		{"other error", time.Second, []error{errOther}, nil, false, 1, "invalid vpc"},
+		{"capacity appears", time.Second, []error{errCapacity, nil}, []bool{false, false, true}, false, 2, ""},
+		{"capacity taken again", time.Second, []error{errCapacity, errCapacity, nil}, []bool{true, true}, false, 3, ""},
+		{"retry without availability", time.Second, []error{errCapacity, errCapacity, nil}, nil, true, 3, ""},
+		{"timeout", 20 * time.Millisecond, []error{errCapacity}, []bool{false, false, false, false, false, false}, false, 2, "gave up waiting for capacity after 20ms"},
+		{"timeout shorter than interval", 2 * time.Millisecond, []error{errCapacity, nil}, []bool{false}, false, 2, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			calls, checks := 0, 0
+			create := func() error {
+				calls++
+				return c.creates[min(calls, len(c.creates))-1]
+			}
+			available := func(context.Context) (bool, error) {
+				checks++
+				return c.available[min(checks, len(c.available))-1], nil
+			}
+			if c.nilCheck {
+				available = nil
+			}
+
+			err := waitForCapacity(context.Background(), c.timeout, 5*time.Millisecond, available, create)
+			if c.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+				t.Fatalf("expected an error containing %q, got %v", c.wantErr, err)
+			}
+			if calls != c.wantCalls {
+				t.Errorf("expected %d create calls, got %d", c.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestIsCapacityError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("400 Bad Request: Insufficient capacity in cluster Msc1"), true},
+		{errors.New("500 Internal Server Error: no available nodes for configuration A100_40GB_SXM_8x"), true},
+		{errors.New("400 Bad Request: Out of capacity"), true},
+		{errors.New("400 Bad Request: GPU capacity exhausted"), true},
+		{errors.New("400 Bad Request: invalid vpc"), false},
+		{nil, false},
+	}
+
+	for _, c := range cases {
+		if got := isCapacityError(c.err); got != c.want {
+			t.Errorf("isCapacityError(%v) = %t, expected %t", c.err, got, c.want)
+		}
+	}
+}
+
+func TestDurationValidator(t *testing.T) {
+	cases := []struct {
+		value   types.String
+		wantErr bool
+	}{
+		{types.StringValue("30m"), false},
+		{types.StringValue("1h30m"), false},
+		{types.StringNull(), false},
+		{types.StringUnknown(), false},
+		{types.StringValue("30"), true},
+		{types.StringValue("-5m"), true},
+		{types.StringValue("0s"), true},
+	}
+
+	for _, c := range cases {
+		req := validator.StringRequest{Path: path.Root("wait_for_capacity_timeout"), ConfigValue: c.value}
+		resp := &validator.StringResponse{}
+		durationValidator{}.ValidateString(context.Background(), req, resp)
+		if resp.Diagnostics.HasError() != c.wantErr {
+			t.Errorf("%s: expected error %t, got %v", c.value, c.wantErr, resp.Diagnostics)
+		}
+	}
+}
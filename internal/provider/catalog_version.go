@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/denvrdata/go-denvr/api/v1/servers/applications"
+	"github.com/hashicorp/go-version"
+)
+
+// latestCatalogVersion selects the newest version of an application catalog item.
+const latestCatalogVersion = "latest"
+
+// catalogVersionNumberRegexp extracts the version number from catalog version names like "python-3.11.9".
+var catalogVersionNumberRegexp = regexp.MustCompile(`\d+(?:\.\d+)*`)
+
+// isCatalogVersionConstraint returns true for "latest" and version constraints like "~> 3.11",
+// which need to be resolved against the application catalog. Anything else is an exact version name.
+func isCatalogVersionConstraint(v string) bool {
+	v = strings.TrimSpace(v)
+	return v == latestCatalogVersion || strings.IndexAny(v, "~<>=!") == 0
+}
+
+// resolveCatalogVersion returns the newest version name of the catalog item which satisfies the constraint.
+func resolveCatalogVersion(items []applications.ApplicationCatalogItem, name string, constraint string) (string, error) {
+	constraint = strings.TrimSpace(constraint)
+
+	var constraints version.Constraints
+	if constraint != latestCatalogVersion {
+		var err error
+		if constraints, err = version.NewConstraint(constraint); err != nil {
+			return "", fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+		}
+	}
+
+	type candidate struct {
+		name    string
+		version *version.Version
+	}
+	var candidates []candidate
+	var available []string
+
+	for _, item := range items {
+		if item.Name == nil || *item.Name != name || item.Versions == nil {
+			continue
+		}
+
+		for _, v := range *item.Versions {
+			if v.Name == nil {
+				continue
+			}
+			available = append(available, *v.Name)
+
+			number := catalogVersionNumberRegexp.FindString(*v.Name)
+			if number == "" {
+				continue
+			}
+
+			parsed, err := version.NewVersion(number)
+			if err != nil || (constraints != nil && !constraints.Check(parsed)) {
+				continue
+			}
+			candidates = append(candidates, candidate{name: *v.Name, version: parsed})
+		}
+	}
+
+	if len(available) == 0 {
+		return "", fmt.Errorf("application catalog item %q not found", name)
+	} else if len(candidates) == 0 {
+		return "", fmt.Errorf("no version of %q satisfies %q, available versions: %s", name, constraint, strings.Join(available, ", "))
+	}
+
+	// Newest first, falling back to the name so the choice is deterministic for equal versions
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].version.Equal(candidates[j].version) {
+			return candidates[i].version.GreaterThan(candidates[j].version)
+		}
+		return candidates[i].name > candidates[j].name
+	})
+
+	return candidates[0].name, nil
+}
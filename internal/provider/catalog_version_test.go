@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/denvrdata/go-denvr/api/v1/servers/applications"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func testCatalogItem(name string, versions ...string) applications.ApplicationCatalogItem {
+	var itemVersions []applications.ApplicationCatalogItemVersion
+	for _, v := range versions {
+		itemVersions = append(itemVersions, applications.ApplicationCatalogItemVersion{Name: &v})
+	}
+	return applications.ApplicationCatalogItem{Name: &name, Versions: &itemVersions}
+}
+
+func TestIsCatalogVersionConstraint(t *testing.T) {
+	cases := map[string]bool{
+		"python-3.11.9":     false,
+		"3.11.9":            false,
+		"latest":            true,
+		"~> 3.11":           true,
+		">= 3.10, < 3.12":   true,
+		" = 3.11.9":         true,
+		"!= 3.11.9":         true,
+		"latest-python-3.9": false,
+	}
+
+	for v, expected := range cases {
+		if actual := isCatalogVersionConstraint(v); actual != expected {
+			t.Errorf("isCatalogVersionConstraint(%q) = %t, expected %t", v, actual, expected)
+		}
+	}
+}
+
+func TestResolveCatalogVersion(t *testing.T) {
+	items := []applications.ApplicationCatalogItem{
+		testCatalogItem("jupyter-notebook", "python-3.10.14", "python-3.11.9", "python-3.11.10", "python-3.12.4", "nightly"),
+		testCatalogItem("vllm", "0.6.3"),
+	}
+
+	cases := map[string]string{
+		"latest":            "python-3.12.4",
+		"~> 3.11":           "python-3.12.4",
+		"~> 3.11.0":         "python-3.11.10",
+		">= 3.10, < 3.11.0": "python-3.10.14",
+		"= 3.11.9":          "python-3.11.9",
+	}
+
+	for constraint, expected := range cases {
+		actual, err := resolveCatalogVersion(items, "jupyter-notebook", constraint)
+		if err != nil {
+			t.Errorf("resolveCatalogVersion(%q) returned error: %v", constraint, err)
+		} else if actual != expected {
+			t.Errorf("resolveCatalogVersion(%q) = %q, expected %q", constraint, actual, expected)
+		}
+	}
+
+	if _, err := resolveCatalogVersion(items, "jupyter-notebook", "~> 4.0"); err == nil {
+		t.Error("expected an error when no version satisfies the constraint")
+	}
+	if _, err := resolveCatalogVersion(items, "jupyter-notebook", "~> foo"); err == nil {
+		t.Error("expected an error for an invalid constraint")
+	}
+	if _, err := resolveCatalogVersion(items, "rstudio", "latest"); err == nil {
+		t.Error("expected an error for a missing catalog item")
+	}
+}
+
+func TestPlanResolvedReplace(t *testing.T) {
+	resolved := func(digest, version types.String) appResourceModel {
+		return appResourceModel{ImageDigest: digest, ResolvedCatalogVersion: version}
+	}
+	digest := types.StringValue("sha256:0123")
+	version := types.StringValue("python-3.11.9")
+
+	cases := []struct {
+		name        string
+		plan        appResourceModel
+		state       appResourceModel
+		want        appResourceModel
+		wantReplace []string
+	}{
+		{"unchanged", resolved(digest, version), resolved(digest, version), resolved(digest, version), nil},
+		{
+			"new version", resolved(digest, types.StringValue("python-3.12.1")), resolved(digest, version),
+			resolved(digest, types.StringValue("python-3.12.1")), []string{"resolved_catalog_version"},
+		},
+		{
+			"new digest", resolved(types.StringValue("sha256:4567"), types.StringNull()), resolved(digest, types.StringNull()),
+			resolved(types.StringValue("sha256:4567"), types.StringNull()), []string{"image_digest"},
+		},
+		// State from older provider versions has neither attribute
+		{"upgraded", resolved(digest, version), resolved(types.StringNull(), types.StringNull()), resolved(types.StringNull(), types.StringNull()), nil},
+		{"upgraded unknown", resolved(types.StringUnknown(), types.StringUnknown()), resolved(types.StringNull(), types.StringNull()), resolved(types.StringNull(), types.StringNull()), nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			plan := c.plan
+			resp := &resource.ModifyPlanResponse{}
+			planResolvedReplace(&plan, c.state, resp)
+
+			if !plan.ImageDigest.Equal(c.want.ImageDigest) || !plan.ResolvedCatalogVersion.Equal(c.want.ResolvedCatalogVersion) {
+				t.Errorf("expected image_digest %s and resolved_catalog_version %s, got %s and %s",
+					c.want.ImageDigest, c.want.ResolvedCatalogVersion, plan.ImageDigest, plan.ResolvedCatalogVersion)
+			}
+			if len(resp.RequiresReplace) != len(c.wantReplace) {
+				t.Fatalf("expected %v to require replacement, got %v", c.wantReplace, resp.RequiresReplace)
+			}
+			for i, attr := range c.wantReplace {
+				if resp.RequiresReplace[i].String() != attr {
+					t.Errorf("expected %s to require replacement, got %s", attr, resp.RequiresReplace[i])
+				}
+			}
+		})
+	}
+}
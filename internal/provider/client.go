@@ -0,0 +1,207 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/denvrdata/go-denvr/api/v1/servers/applications"
+	"github.com/denvrdata/go-denvr/api/v1/servers/virtual"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+)
+
+// denvrClient is shared by all resources through the provider's ResourceData and holds the
+// resolved endpoint and authentication for go-denvr API clients.
+type denvrClient struct {
+	server     string
+	httpClient *http.Client
+	auth       *denvrAuth
+
+	// transport has the proxy and TLS settings without the Denvr API retries and limits,
+	// for requests to other services like container registries
+	transport http.RoundTripper
+
+	tracing *denvrTracing
+
+	// auditLog records mutating API calls, nil unless audit_log_file is set
+	auditLog *auditLogger
+
+	policy *guardrailPolicy
+
+	// readOnly rejects changes to resources
+	readOnly bool
+
+	// capacity adds up the VMs planned across resources, and strictCapacityCheck fails plans which exceed it
+	capacity            *capacityTracker
+	strictCapacityCheck bool
+}
+
+// denvrClientOptions configure the HTTP behaviour shared by every request the provider makes.
+type denvrClientOptions struct {
+	// Transport is the base transport, http.DefaultTransport if nil
+	Transport http.RoundTripper
+
+	MaxRetries   int
+	RetryMaxWait time.Duration
+
+	// Zero disables the respective limit
+	MaxConcurrentRequests int
+	RequestsPerSecond     float64
+
+	// Tracing is disabled if nil
+	Tracing *denvrTracing
+
+	// AuditLog is disabled if nil
+	AuditLog *auditLogger
+
+	// Policy is enforced when planning resources, nil for no limits
+	Policy *guardrailPolicy
+
+	// ReadOnly rejects every request which could change resources
+	ReadOnly bool
+
+	// StrictCapacityCheck fails plans which need more VMs than are available, rather than warning
+	StrictCapacityCheck bool
+}
+
+func newDenvrClient(creds denvrCredentials, opts denvrClientOptions) *denvrClient {
+	base := opts.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	tracing := opts.Tracing
+	if tracing == nil {
+		tracing = disabledTracing()
+	}
+
+	// Retries sit above the limiter so every attempt counts against the request budget,
+	// and logging sits below it so every attempt is logged. Each attempt gets its own span,
+	// which includes any time spent waiting on the limiter.
+	transport := base
+	transport = &loggingTransport{base: transport, secrets: []string{creds.Password}}
+	transport = &limitTransport{base: transport, limiter: newRequestLimiter(opts.MaxConcurrentRequests, opts.RequestsPerSecond)}
+	transport = &tracingTransport{base: transport, tracer: tracing.tracer}
+	transport = newRetryTransport(transport, opts.MaxRetries, opts.RetryMaxWait)
+	if opts.ReadOnly {
+		// Outermost so rejected requests are never retried or counted against the limits
+		transport = newReadOnlyTransport(transport, creds.Server)
+	}
+
+	httpClient := &http.Client{
+		Timeout:   5 * time.Minute,
+		Transport: transport,
+	}
+	return &denvrClient{
+		server:     creds.Server,
+		httpClient: httpClient,
+		auth:       newDenvrAuth(httpClient, creds),
+		transport:  base,
+		tracing:    tracing,
+		auditLog:   opts.AuditLog,
+		policy:     opts.Policy,
+		readOnly:   opts.ReadOnly,
+
+		capacity:            newCapacityTracker(),
+		strictCapacityCheck: opts.StrictCapacityCheck,
+	}
+}
+
+func (c *denvrClient) applications() applications.Client {
+	return applications.Client{
+		Server:         c.server,
+		Client:         c.httpClient,
+		RequestEditors: []applications.RequestEditorFn{c.auth.Intercept},
+	}
+}
+
+func (c *denvrClient) virtual() virtual.Client {
+	return virtual.Client{
+		Server:         c.server,
+		Client:         c.httpClient,
+		RequestEditors: []virtual.RequestEditorFn{c.auth.Intercept},
+	}
+}
+
+// getResult makes an authenticated GET request to a Denvr API endpoint which go-denvr doesn't
+// cover and returns the result of the response.
+func (c *denvrClient) getResult(ctx context.Context, apiPath string, query url.Values) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.server+apiPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.auth.Intercept(ctx, req); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Result json.RawMessage `json:"result"`
+		Error  *denvrAPIError  `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("unable to parse %s response (%s): %w", apiPath, resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s request failed: %s", apiPath, result.Error.format(resp.Status))
+	}
+	return result.Result, nil
+}
+
+// decodeItems decodes a result which is either a list of items or a page with the list in its items.
+func decodeItems[T any](result json.RawMessage) ([]T, error) {
+	var items []T
+	if err := json.Unmarshal(result, &items); err == nil {
+		return items, nil
+	}
+
+	var paged struct {
+		Items []T `json:"items"`
+	}
+	if err := json.Unmarshal(result, &paged); err != nil {
+		return nil, err
+	}
+	return paged.Items, nil
+}
+
+// configureClient extracts the denvrClient from the provider data passed to a resource's Configure.
+// The provider data is nil until the provider itself has been configured.
+func configureClient(req resource.ConfigureRequest, resp *resource.ConfigureResponse) *denvrClient {
+	if req.ProviderData == nil {
+		return nil
+	}
+
+	client, ok := req.ProviderData.(*denvrClient)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *denvrClient, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return nil
+	}
+	return client
+}
+
+// deferUnconfigured handles resource calls made without a client, which happens when the provider
+// configuration depends on values that aren't known yet. Terraform 1.9+ run with -allow-deferral can
+// defer the resource to a later plan, older versions get an error.
+func deferUnconfigured(deferralAllowed bool, diags *diag.Diagnostics) *resource.Deferred {
+	if deferralAllowed {
+		return &resource.Deferred{Reason: resource.DeferredReasonProviderConfigUnknown}
+	}
+
+	diags.AddError(
+		"Unconfigured Denvr Provider",
+		"The denvr provider configuration depends on values that are not known until apply. "+
+			"Run Terraform 1.9 or later with -allow-deferral, or apply the resources the provider configuration depends on first with -target.",
+	)
+	return nil
+}
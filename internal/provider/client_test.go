@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestDeferUnconfigured(t *testing.T) {
+	var diags diag.Diagnostics
+	deferred := deferUnconfigured(true, &diags)
+	if deferred == nil || deferred.Reason != resource.DeferredReasonProviderConfigUnknown {
+		t.Errorf("expected a provider config unknown deferral, got %+v", deferred)
+	}
+	if diags.HasError() {
+		t.Errorf("unexpected diagnostics: %v", diags)
+	}
+
+	deferred = deferUnconfigured(false, &diags)
+	if deferred != nil {
+		t.Errorf("expected no deferral without client support, got %+v", deferred)
+	}
+	if !diags.HasError() || diags[0].Summary() != "Unconfigured Denvr Provider" {
+		t.Errorf("expected an unconfigured provider error, got %v", diags)
+	}
+}
+
+func TestProviderUnknownAttributes(t *testing.T) {
+	data := denvrProviderModel{
+		CredentialProcess:         types.StringNull(),
+		Password:                  types.StringUnknown(),
+		Profile:                   types.StringNull(),
+		Server:                    types.StringValue("https://api.cloud.denvrdata.com"),
+		SkipCredentialsValidation: types.BoolNull(),
+		Username:                  types.StringUnknown(),
+	}
+
+	unknown := data.unknownAttributes()
+	if len(unknown) != 2 || unknown[0] != "password" || unknown[1] != "username" {
+		t.Errorf("expected password and username to be unknown, got %v", unknown)
+	}
+}
@@ -0,0 +1,287 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// defaultServer is the Denvr Cloud API used when no source provides a server.
+const defaultServer = "https://api.cloud.denvrdata.com"
+
+// denvrCredentials are the API endpoint and login resolved by the credential chain.
+type denvrCredentials struct {
+	Server   string
+	Username string
+	Password string
+
+	// Source describes where the username and password came from, for logs and diagnostics.
+	Source string
+}
+
+// credentialSource is one link in the credential chain. Sources return partial credentials
+// (e.g. only a server) and errNoCredentials when they have nothing to offer.
+type credentialSource struct {
+	name    string
+	resolve func(ctx context.Context) (denvrCredentials, error)
+
+	// runsCommand marks sources which run an external command, which are skipped once a login is found
+	runsCommand bool
+}
+
+var errNoCredentials = errors.New("not set")
+
+// resolveCredentials walks the credential sources in order. The username and password are taken
+// together from the first source which provides both, while the server is taken from the first
+// source which provides one, so the two may come from different sources. Once the username and
+// password are found, the remaining sources are only read for a server and their errors are ignored,
+// except for sources which run a command, like credential_process, which aren't run at all.
+// If no source provides a login, the error names every source tried. The server is resolved
+// even when an error is returned, for a provider which skips credentials validation.
+func resolveCredentials(ctx context.Context, sources []credentialSource) (creds denvrCredentials, err error) {
+	var tried []string
+	defer func() {
+		if creds.Server == "" {
+			creds.Server = defaultServer
+		}
+		creds.Server = strings.TrimSuffix(creds.Server, "/")
+	}()
+
+	for _, source := range sources {
+		if creds.Source != "" && creds.Server != "" {
+			break
+		}
+		if creds.Source != "" && source.runsCommand {
+			continue
+		}
+
+		found, err := source.resolve(ctx)
+		if err != nil && !errors.Is(err, errNoCredentials) {
+			if creds.Source != "" {
+				tflog.Debug(ctx, fmt.Sprintf("Ignoring %s after finding credentials: %s", source.name, err))
+				continue
+			}
+			return creds, fmt.Errorf("%s: %w", source.name, err)
+		}
+
+		if creds.Server == "" && found.Server != "" {
+			tflog.Debug(ctx, "Using Denvr server from "+source.name)
+			creds.Server = found.Server
+		}
+
+		if creds.Source == "" {
+			if found.Username != "" && found.Password != "" {
+				tflog.Debug(ctx, "Using Denvr credentials from "+source.name)
+				creds.Username = found.Username
+				creds.Password = found.Password
+				creds.Source = source.name
+			} else if err != nil {
+				tried = append(tried, fmt.Sprintf("%s: %s", source.name, err))
+			} else {
+				tried = append(tried, fmt.Sprintf("%s: username and password not both set", source.name))
+			}
+		}
+	}
+
+	if creds.Source == "" {
+		return creds, fmt.Errorf("no Denvr credentials found, tried:\n  - %s", strings.Join(tried, "\n  - "))
+	}
+	return creds, nil
+}
+
+// staticCredentials returns the credentials explicitly set on the provider block.
+func staticCredentials(creds denvrCredentials) credentialSource {
+	return credentialSource{
+		name: "provider attributes (server, username, password)",
+		resolve: func(ctx context.Context) (denvrCredentials, error) {
+			if creds == (denvrCredentials{}) {
+				return creds, errNoCredentials
+			}
+			return creds, nil
+		},
+	}
+}
+
+// envCredentials returns the credentials from the DENVR_SERVER, DENVR_USERNAME and DENVR_PASSWORD environment variables.
+func envCredentials() credentialSource {
+	return credentialSource{
+		name: "environment variables (DENVR_SERVER, DENVR_USERNAME, DENVR_PASSWORD)",
+		resolve: func(ctx context.Context) (denvrCredentials, error) {
+			creds := denvrCredentials{
+				Server:   os.Getenv("DENVR_SERVER"),
+				Username: os.Getenv("DENVR_USERNAME"),
+				Password: os.Getenv("DENVR_PASSWORD"),
+			}
+			if creds == (denvrCredentials{}) {
+				return creds, errNoCredentials
+			}
+			return creds, nil
+		},
+	}
+}
+
+// processCredentials runs an external command which prints the credentials as JSON, e.g.
+// `{"username": "...", "password": "...", "server": "..."}`, similar to the AWS credential_process.
+func processCredentials(command string) credentialSource {
+	return credentialSource{
+		name:        "credential_process",
+		runsCommand: true,
+		resolve: func(ctx context.Context) (denvrCredentials, error) {
+			var creds denvrCredentials
+			if command == "" {
+				return creds, errNoCredentials
+			}
+
+			var cmd *exec.Cmd
+			if runtime.GOOS == "windows" {
+				cmd = exec.CommandContext(ctx, "cmd.exe", "/C", command)
+			} else {
+				cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+			}
+
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			if err := cmd.Run(); err != nil {
+				return creds, fmt.Errorf("command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+			}
+
+			var output struct {
+				Server   string `json:"server"`
+				Username string `json:"username"`
+				Password string `json:"password"`
+			}
+			if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+				return creds, fmt.Errorf("unable to parse command output as JSON: %w", err)
+			}
+
+			creds.Server = output.Server
+			creds.Username = output.Username
+			creds.Password = output.Password
+			return creds, nil
+		},
+	}
+}
+
+// denvrConfigFile is the TOML config file shared with the other Denvr tools, e.g.
+//
+//	[defaults]
+//	server = "https://api.cloud.denvrdata.com"
+//	retries = 5
+//
+//	[credentials]
+//	username = "test@foobar.com"
+//	password = "..."
+//
+//	[profile.staging]
+//	server = "https://api.staging.denvrdata.com"
+//	username = "test@foobar.com"
+//	password = "..."
+type denvrConfigFile struct {
+	Defaults struct {
+		Server  string `toml:"server"`
+		Retries *int   `toml:"retries"`
+	} `toml:"defaults"`
+	Credentials struct {
+		Username string `toml:"username"`
+		Password string `toml:"password"`
+	} `toml:"credentials"`
+	Profiles map[string]denvrConfigProfile `toml:"profile"`
+}
+
+// denvrConfigProfile is a named `[profile.<name>]` section, used to target several tenants from one config file.
+type denvrConfigProfile struct {
+	Server   string `toml:"server"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+}
+
+// denvrConfigPath returns DENVR_CONFIG if set or the default ~/.config/denvr.toml.
+func denvrConfigPath() string {
+	if path := os.Getenv("DENVR_CONFIG"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "denvr.toml")
+}
+
+// fileCredentials returns the credentials from the Denvr config file. When a profile is given its
+// `[profile.<name>]` section is used instead of `[credentials]`, falling back to the `[defaults]` server.
+func fileCredentials(path string, profile string) credentialSource {
+	name := fmt.Sprintf("config file %q", path)
+	if profile != "" {
+		name = fmt.Sprintf("config file %q (profile %q)", path, profile)
+	}
+
+	return credentialSource{
+		name: name,
+		resolve: func(ctx context.Context) (denvrCredentials, error) {
+			var creds denvrCredentials
+			if path == "" {
+				return creds, errNoCredentials
+			}
+
+			var config denvrConfigFile
+			if _, err := toml.DecodeFile(path, &config); errors.Is(err, os.ErrNotExist) {
+				return creds, fmt.Errorf("file not found: %w", errNoCredentials)
+			} else if err != nil {
+				return creds, fmt.Errorf("unable to parse config file: %w", err)
+			}
+
+			creds.Server = config.Defaults.Server
+			if profile == "" {
+				creds.Username = config.Credentials.Username
+				creds.Password = config.Credentials.Password
+				return creds, nil
+			}
+
+			section, ok := config.Profiles[profile]
+			if !ok {
+				available := slices.Sorted(maps.Keys(config.Profiles))
+				return creds, fmt.Errorf("profile %q not found, available profiles: [%s]", profile, strings.Join(available, ", "))
+			}
+
+			if section.Server != "" {
+				creds.Server = section.Server
+			}
+			creds.Username = section.Username
+			creds.Password = section.Password
+			return creds, nil
+		},
+	}
+}
+
+// denvrProfile returns the configured profile name, falling back to DENVR_PROFILE.
+func denvrProfile(profile string) string {
+	if profile != "" {
+		return profile
+	}
+	return os.Getenv("DENVR_PROFILE")
+}
+
+// denvrConfigRetries returns the `[defaults] retries` setting of the config file, if any.
+func denvrConfigRetries(path string) (int, bool) {
+	var config denvrConfigFile
+	if path == "" {
+		return 0, false
+	} else if _, err := toml.DecodeFile(path, &config); err != nil || config.Defaults.Retries == nil {
+		return 0, false
+	}
+	return *config.Defaults.Retries, true
+}
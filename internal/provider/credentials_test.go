@@ -0,0 +1,300 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func writeDenvrConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "denvr.toml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clearDenvrEnv makes sure credentials from the developer's environment don't leak into the tests.
+func clearDenvrEnv(t *testing.T) {
+	for _, name := range []string{"DENVR_SERVER", "DENVR_USERNAME", "DENVR_PASSWORD", "DENVR_PROFILE"} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestResolveCredentials(t *testing.T) {
+	configPath := writeDenvrConfig(t, `
+[defaults]
+server = "https://file.example.com"
+
+[credentials]
+username = "file@example.com"
+password = "file-password"
+
+[profile.staging]
+server = "https://staging.example.com"
+username = "staging@example.com"
+password = "staging-password"
+
+[profile.production]
+username = "production@example.com"
+password = "production-password"
+`)
+	serverOnlyPath := writeDenvrConfig(t, `
+[defaults]
+server = "https://file.example.com"
+`)
+	invalidPath := writeDenvrConfig(t, "[credentials\n")
+	processCommand := `echo '{"username": "process@example.com", "password": "process-password"}'`
+
+	cases := []struct {
+		name    string
+		env     map[string]string
+		static  denvrCredentials
+		process string
+		config  string
+		profile string
+		want    denvrCredentials
+	}{
+		{
+			name:    "static",
+			static:  denvrCredentials{Username: "static@example.com", Password: "static-password"},
+			env:     map[string]string{"DENVR_USERNAME": "env@example.com", "DENVR_PASSWORD": "env-password"},
+			process: processCommand,
+			config:  configPath,
+			want:    denvrCredentials{Server: "https://file.example.com", Username: "static@example.com", Password: "static-password"},
+		},
+		{
+			name:    "static with broken lower sources",
+			static:  denvrCredentials{Username: "static@example.com", Password: "static-password"},
+			process: "echo oops >&2; exit 3",
+			config:  invalidPath,
+			want:    denvrCredentials{Server: defaultServer, Username: "static@example.com", Password: "static-password"},
+		},
+		{
+			name:    "environment",
+			env:     map[string]string{"DENVR_USERNAME": "env@example.com", "DENVR_PASSWORD": "env-password", "DENVR_SERVER": "https://env.example.com/"},
+			process: processCommand,
+			config:  configPath,
+			want:    denvrCredentials{Server: "https://env.example.com", Username: "env@example.com", Password: "env-password"},
+		},
+		{
+			name:    "partial environment",
+			env:     map[string]string{"DENVR_USERNAME": "env@example.com"},
+			process: processCommand,
+			config:  configPath,
+			want:    denvrCredentials{Server: "https://file.example.com", Username: "process@example.com", Password: "process-password"},
+		},
+		{
+			name:   "static server",
+			static: denvrCredentials{Server: "https://static.example.com"},
+			config: configPath,
+			want:   denvrCredentials{Server: "https://static.example.com", Username: "file@example.com", Password: "file-password"},
+		},
+		{
+			name:    "process",
+			process: processCommand,
+			config:  serverOnlyPath,
+			want:    denvrCredentials{Server: "https://file.example.com", Username: "process@example.com", Password: "process-password"},
+		},
+		{
+			name:   "config file",
+			config: configPath,
+			want:   denvrCredentials{Server: "https://file.example.com", Username: "file@example.com", Password: "file-password"},
+		},
+		{
+			name:    "profile",
+			config:  configPath,
+			profile: "staging",
+			want:    denvrCredentials{Server: "https://staging.example.com", Username: "staging@example.com", Password: "staging-password"},
+		},
+		{
+			name:    "profile default server",
+			config:  configPath,
+			profile: "production",
+			want:    denvrCredentials{Server: "https://file.example.com", Username: "production@example.com", Password: "production-password"},
+		},
+		{
+			name:    "default server",
+			process: processCommand,
+			want:    denvrCredentials{Server: defaultServer, Username: "process@example.com", Password: "process-password"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clearDenvrEnv(t)
+			for name, value := range c.env {
+				t.Setenv(name, value)
+			}
+
+			creds, err := resolveCredentials(context.Background(), []credentialSource{
+				staticCredentials(c.static),
+				envCredentials(),
+				processCredentials(c.process),
+				fileCredentials(c.config, c.profile),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			creds.Source = ""
+			if creds != c.want {
+				t.Errorf("got %+v, want %+v", creds, c.want)
+			}
+		})
+	}
+}
+
+func TestResolveCredentials_skipsProcess(t *testing.T) {
+	clearDenvrEnv(t)
+	marker := filepath.Join(t.TempDir(), "ran")
+	configPath := writeDenvrConfig(t, `
+[defaults]
+server = "https://file.example.com"
+`)
+
+	// The server is still read from the config file, but the command isn't run once a login is found
+	creds, err := resolveCredentials(context.Background(), []credentialSource{
+		staticCredentials(denvrCredentials{Username: "static@example.com", Password: "static-password"}),
+		processCredentials("touch " + marker),
+		fileCredentials(configPath, ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.Server != "https://file.example.com" || creds.Username != "static@example.com" {
+		t.Errorf("unexpected credentials: %+v", creds)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("expected credential_process not to run, got %v", err)
+	}
+}
+
+func TestResolveCredentials_notFound(t *testing.T) {
+	clearDenvrEnv(t)
+	t.Setenv("DENVR_USERNAME", "env@example.com")
+	missing := filepath.Join(t.TempDir(), "missing.toml")
+
+	creds, err := resolveCredentials(context.Background(), []credentialSource{
+		staticCredentials(denvrCredentials{}),
+		envCredentials(),
+		processCredentials(""),
+		fileCredentials(missing, ""),
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// The server is still resolved for providers which skip credentials validation
+	if creds.Server != defaultServer {
+		t.Errorf("expected the default server, got %q", creds.Server)
+	}
+
+	for _, want := range []string{
+		"provider attributes (server, username, password): not set",
+		"environment variables (DENVR_SERVER, DENVR_USERNAME, DENVR_PASSWORD): username and password not both set",
+		"credential_process: not set",
+		missing,
+		"file not found",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got:\n%s", want, err)
+		}
+	}
+}
+
+func TestResolveCredentials_errors(t *testing.T) {
+	clearDenvrEnv(t)
+	invalid := writeDenvrConfig(t, "[credentials\n")
+	profiles := writeDenvrConfig(t, `
+[profile.staging]
+username = "staging@example.com"
+
+[profile.production]
+username = "production@example.com"
+`)
+
+	cases := []struct {
+		name   string
+		source credentialSource
+		want   string
+	}{
+		{"process failure", processCredentials("echo oops >&2; exit 3"), "oops"},
+		{"process output", processCredentials("echo not-json"), "unable to parse command output as JSON"},
+		{"config file", fileCredentials(invalid, ""), "unable to parse config file"},
+		{"missing profile", fileCredentials(profiles, "dev"), `profile "dev" not found, available profiles: [production, staging]`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := resolveCredentials(context.Background(), []credentialSource{c.source})
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("expected error containing %q, got %v", c.want, err)
+			}
+		})
+	}
+}
+
+func TestProviderCredentialSources(t *testing.T) {
+	configPath := writeDenvrConfig(t, `
+[profile.staging]
+server = "https://staging.example.com"
+username = "staging@example.com"
+password = "staging-password"
+`)
+
+	cases := []struct {
+		name       string
+		profile    types.String
+		username   types.String
+		envProfile string
+		want       string
+		wantErr    string
+	}{
+		{"explicit profile before environment", types.StringValue("staging"), types.StringNull(), "", "staging@example.com", ""},
+		{"DENVR_PROFILE after environment", types.StringNull(), types.StringNull(), "staging", "env@example.com", ""},
+		{"missing profile after static credentials", types.StringValue("dev"), types.StringValue("static@example.com"), "", "static@example.com", ""},
+		{"missing explicit profile", types.StringValue("dev"), types.StringNull(), "", "", `profile "dev" not found`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clearDenvrEnv(t)
+			t.Setenv("DENVR_USERNAME", "env@example.com")
+			t.Setenv("DENVR_PASSWORD", "env-password")
+			t.Setenv("DENVR_PROFILE", c.envProfile)
+
+			data := denvrProviderModel{Profile: c.profile, Username: c.username, Password: types.StringValue("static-password")}
+			if c.username.IsNull() {
+				data.Password = types.StringNull()
+			}
+
+			creds, err := resolveCredentials(context.Background(), data.credentialSources(configPath))
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if creds.Username != c.want {
+				t.Errorf("expected credentials for %s, got %s from %s", c.want, creds.Username, creds.Source)
+			}
+		})
+	}
+}
+
+func TestDenvrConfigRetries(t *testing.T) {
+	if retries, ok := denvrConfigRetries(writeDenvrConfig(t, "[defaults]\nretries = 2\n")); !ok || retries != 2 {
+		t.Errorf("expected 2 retries, got %d", retries)
+	}
+	if _, ok := denvrConfigRetries(writeDenvrConfig(t, "[defaults]\nserver = \"https://api.cloud.denvrdata.com\"\n")); ok {
+		t.Error("expected no retries setting")
+	}
+}
@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// deletionProtectionAttribute is the deletion_protection attribute shared by the resources
+// which hold GPU capacity, where kind names the resource in its description.
+func deletionProtectionAttribute(kind string) schema.BoolAttribute {
+	return schema.BoolAttribute{
+		Optional: true,
+		Computed: true,
+		Default:  booldefault.StaticBool(false),
+		MarkdownDescription: fmt.Sprintf("Prevent the %s from being destroyed or replaced. "+
+			"It must be set to `false` in a separate apply before the %s can be destroyed or replaced. Defaults to `false`.", kind, kind),
+	}
+}
+
+// checkReplaceProtection fails a plan which replaces a resource whose prior state has
+// deletion_protection set. It must run after everything else that adds to resp.RequiresReplace.
+func checkReplaceProtection(ctx context.Context, kind string, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.State.Raw.IsNull() || req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var protected types.Bool
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("deletion_protection"), &protected)...)
+	if !protected.ValueBool() {
+		return
+	}
+
+	replaced, diags := replacedAttributes(ctx, req)
+	resp.Diagnostics.Append(diags...)
+	for _, p := range resp.RequiresReplace {
+		if !replaced.Contains(p) {
+			replaced = append(replaced, p)
+		}
+	}
+	if len(replaced) == 0 {
+		return
+	}
+
+	attrs := make([]string, 0, len(replaced))
+	for _, p := range replaced {
+		attrs = append(attrs, p.String())
+	}
+	sort.Strings(attrs)
+	resp.Diagnostics.AddError(
+		"Deletion Protection Enabled",
+		fmt.Sprintf("Changing %s requires replacing the %s, but it has deletion_protection set. ", strings.Join(attrs, ", "), kind)+
+			"Revert the change, or set deletion_protection = false and apply that on its own first.",
+	)
+}
+
+// replacedAttributes returns the attributes whose plan modifiers require the resource to be replaced.
+// The framework only adds those to RequiresReplace after the resource's ModifyPlan has run, so the
+// plan modifiers of the top-level attributes are run again here against the final plan.
+func replacedAttributes(ctx context.Context, req resource.ModifyPlanRequest) (path.Paths, diag.Diagnostics) {
+	var replaced path.Paths
+	var diags diag.Diagnostics
+	for name, attr := range req.Plan.Schema.GetAttributes() {
+		attrPath := path.Root(name)
+		switch a := attr.(type) {
+		case schema.StringAttribute:
+			if len(a.PlanModifiers) == 0 {
+				continue
+			}
+			var config, plan, state types.String
+			diags.Append(req.Config.GetAttribute(ctx, attrPath, &config)...)
+			diags.Append(req.Plan.GetAttribute(ctx, attrPath, &plan)...)
+			diags.Append(req.State.GetAttribute(ctx, attrPath, &state)...)
+			if diags.HasError() {
+				return nil, diags
+			}
+
+			modifyReq := planmodifier.StringRequest{
+				Path: attrPath, PathExpression: attrPath.Expression(), Private: req.Private,
+				Config: req.Config, ConfigValue: config, Plan: req.Plan, PlanValue: plan, State: req.State, StateValue: state,
+			}
+			for _, m := range a.PlanModifiers {
+				modifyResp := &planmodifier.StringResponse{PlanValue: plan}
+				m.PlanModifyString(ctx, modifyReq, modifyResp)
+				if modifyResp.RequiresReplace {
+					replaced = append(replaced, attrPath)
+					break
+				}
+			}
+		case schema.Int64Attribute:
+			if len(a.PlanModifiers) == 0 {
+				continue
+			}
+			var config, plan, state types.Int64
+			diags.Append(req.Config.GetAttribute(ctx, attrPath, &config)...)
+			diags.Append(req.Plan.GetAttribute(ctx, attrPath, &plan)...)
+			diags.Append(req.State.GetAttribute(ctx, attrPath, &state)...)
+			if diags.HasError() {
+				return nil, diags
+			}
+
+			modifyReq := planmodifier.Int64Request{
+				Path: attrPath, PathExpression: attrPath.Expression(), Private: req.Private,
+				Config: req.Config, ConfigValue: config, Plan: req.Plan, PlanValue: plan, State: req.State, StateValue: state,
+			}
+			for _, m := range a.PlanModifiers {
+				modifyResp := &planmodifier.Int64Response{PlanValue: plan}
+				m.PlanModifyInt64(ctx, modifyReq, modifyResp)
+				if modifyResp.RequiresReplace {
+					replaced = append(replaced, attrPath)
+					break
+				}
+			}
+		}
+	}
+	return replaced, diags
+}
+
+// checkDeleteProtection adds an error and returns true if a resource being destroyed has
+// deletion_protection set.
+func checkDeleteProtection(kind, id string, protected types.Bool, diags *diag.Diagnostics) bool {
+	if !protected.ValueBool() {
+		return false
+	}
+
+	diags.AddError(
+		"Deletion Protection Enabled",
+		fmt.Sprintf("The %s %q has deletion_protection set and was not destroyed. ", kind, id)+
+			"Set deletion_protection = false and apply that on its own before destroying it.",
+	)
+	return true
+}
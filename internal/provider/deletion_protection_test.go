@@ -0,0 +1,136 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestCheckReplaceProtection(t *testing.T) {
+	s := schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"name":                schema.StringAttribute{Required: true},
+			"deletion_protection": deletionProtectionAttribute("VM"),
+		},
+	}
+	objectType := s.Type().TerraformType(context.Background())
+	value := func(protected bool) tftypes.Value {
+		return tftypes.NewValue(objectType, map[string]tftypes.Value{
+			"name":                tftypes.NewValue(tftypes.String, "my-vm"),
+			"deletion_protection": tftypes.NewValue(tftypes.Bool, protected),
+		})
+	}
+	null := tftypes.NewValue(objectType, nil)
+
+	cases := []struct {
+		name      string
+		state     tftypes.Value
+		plan      tftypes.Value
+		replace   path.Paths
+		wantError bool
+	}{
+		{"replace protected", value(true), value(true), path.Paths{path.Root("name")}, true},
+		{"turning protection off still replaces", value(true), value(false), path.Paths{path.Root("name")}, true},
+		{"replace unprotected", value(false), value(false), path.Paths{path.Root("name")}, false},
+		{"update protected", value(true), value(false), nil, false},
+		{"create", null, value(true), path.Paths{path.Root("name")}, false},
+		{"destroy", value(true), null, nil, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := resource.ModifyPlanRequest{
+				State: tfsdk.State{Schema: s, Raw: c.state},
+				Plan:  tfsdk.Plan{Schema: s, Raw: c.plan},
+			}
+			resp := &resource.ModifyPlanResponse{RequiresReplace: c.replace}
+
+			checkReplaceProtection(context.Background(), "VM", req, resp)
+			if resp.Diagnostics.HasError() != c.wantError {
+				t.Fatalf("expected error %t, got %v", c.wantError, resp.Diagnostics)
+			}
+			if c.wantError && !strings.Contains(resp.Diagnostics.Errors()[0].Detail(), "name") {
+				t.Errorf("expected the error to name the replaced attribute, got %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestCheckReplaceProtection_attributePlanModifiers(t *testing.T) {
+	ctx := context.Background()
+	var schemaResp resource.SchemaResponse
+	(&appResource{}).Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	s := schemaResp.Schema
+
+	objectType := s.Type().TerraformType(ctx).(tftypes.Object)
+	value := func(protected bool, tokenVersion int64) tftypes.Value {
+		attrs := make(map[string]tftypes.Value, len(objectType.AttributeTypes))
+		for name, typ := range objectType.AttributeTypes {
+			attrs[name] = tftypes.NewValue(typ, nil)
+		}
+		attrs["name"] = tftypes.NewValue(tftypes.String, "my-app")
+		attrs["deletion_protection"] = tftypes.NewValue(tftypes.Bool, protected)
+		attrs["jupyter_token_wo_version"] = tftypes.NewValue(tftypes.Number, tokenVersion)
+		return tftypes.NewValue(objectType, attrs)
+	}
+
+	cases := []struct {
+		name      string
+		state     tftypes.Value
+		plan      tftypes.Value
+		wantError bool
+	}{
+		{"replace protected", value(true, 1), value(true, 2), true},
+		{"replace unprotected", value(false, 1), value(false, 2), false},
+		{"update protected", value(true, 1), value(false, 1), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// The framework hasn't added the replacements planned by attribute plan modifiers yet
+			req := resource.ModifyPlanRequest{
+				Config: tfsdk.Config{Schema: s, Raw: c.plan},
+				State:  tfsdk.State{Schema: s, Raw: c.state},
+				Plan:   tfsdk.Plan{Schema: s, Raw: c.plan},
+			}
+			resp := &resource.ModifyPlanResponse{Plan: req.Plan}
+
+			checkReplaceProtection(ctx, "application", req, resp)
+			if resp.Diagnostics.HasError() != c.wantError {
+				t.Fatalf("expected error %t, got %v", c.wantError, resp.Diagnostics)
+			}
+			if c.wantError && !strings.Contains(resp.Diagnostics.Errors()[0].Detail(), "jupyter_token_wo_version") {
+				t.Errorf("expected the error to name jupyter_token_wo_version, got %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestCheckDeleteProtection(t *testing.T) {
+	cases := []struct {
+		name      string
+		protected types.Bool
+		want      bool
+	}{
+		{"protected", types.BoolValue(true), true},
+		{"unprotected", types.BoolValue(false), false},
+		{"state from an older provider", types.BoolNull(), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var diags diag.Diagnostics
+			if got := checkDeleteProtection("application", "my-app", c.protected, &diags); got != c.want || diags.HasError() != c.want {
+				t.Errorf("expected %t, got %t with %v", c.want, got, diags)
+			}
+		})
+	}
+}
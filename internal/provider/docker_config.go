@@ -0,0 +1,202 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// dockerHubServerURL is the key docker uses for Docker Hub in config files and credential helpers.
+const dockerHubServerURL = "https://index.docker.io/v1/"
+
+// dockerConfigFile is the subset of ~/.docker/config.json we need to resolve registry credentials.
+type dockerConfigFile struct {
+	Auths       map[string]dockerAuthConfig `json:"auths"`
+	CredsStore  string                      `json:"credsStore"`
+	CredHelpers map[string]string           `json:"credHelpers"`
+}
+
+type dockerAuthConfig struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+// dockerCredentials are the resolved credentials for a single registry.
+type dockerCredentials struct {
+	ServerURL string
+	Username  string
+	Password  string
+}
+
+// errDockerCredentialsNotFound is returned when no credentials exist for a registry.
+var errDockerCredentialsNotFound = errors.New("credentials not found")
+
+// dockerConfigPath returns the docker config file location, honouring DOCKER_CONFIG like the docker CLI.
+func dockerConfigPath() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".docker", "config.json"), nil
+}
+
+func loadDockerConfig(path string) (*dockerConfigFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read docker config %q: %w", path, err)
+	}
+
+	var config dockerConfigFile
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("unable to parse docker config %q: %w", path, err)
+	}
+	return &config, nil
+}
+
+// resolveDockerCredentials looks up credentials for the registry hostname in the user's docker config.
+func resolveDockerCredentials(ctx context.Context, host string) (*dockerCredentials, error) {
+	path, err := dockerConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := loadDockerConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := config.credentials(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve credentials for %q from %q: %w", host, path, err)
+	}
+	return creds, nil
+}
+
+// credentials resolves credentials for a registry hostname in the same order as the docker CLI:
+// a registry specific credHelpers entry, then the default credsStore, then the plain auths entries.
+func (c *dockerConfigFile) credentials(ctx context.Context, host string) (*dockerCredentials, error) {
+	serverURL := host
+	if host == dockerHubRegistry {
+		serverURL = dockerHubServerURL
+	}
+
+	// Keys are matched like the auths entries, as Docker Hub helpers are keyed by "index.docker.io"
+	// or "https://index.docker.io/v1/" rather than "docker.io"
+	for _, key := range slices.Sorted(maps.Keys(c.CredHelpers)) {
+		if normalizeDockerServer(key) == host {
+			helper := c.CredHelpers[key]
+			tflog.Debug(ctx, fmt.Sprintf("Using docker credential helper %q for %s", helper, host))
+			return runDockerCredentialHelper(ctx, helper, serverURL)
+		}
+	}
+
+	if c.CredsStore != "" {
+		tflog.Debug(ctx, fmt.Sprintf("Using docker credential store %q for %s", c.CredsStore, host))
+		creds, err := runDockerCredentialHelper(ctx, c.CredsStore, serverURL)
+		if !errors.Is(err, errDockerCredentialsNotFound) {
+			return creds, err
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(c.Auths)) {
+		auth := c.Auths[key]
+		if normalizeDockerServer(key) != host {
+			continue
+		}
+
+		tflog.Debug(ctx, fmt.Sprintf("Using docker config auths entry %q for %s", key, host))
+		creds := dockerCredentials{
+			ServerURL: key,
+			Username:  auth.Username,
+			Password:  auth.Password,
+		}
+
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth entry for %q: %w", key, err)
+			}
+
+			username, password, found := strings.Cut(string(decoded), ":")
+			if !found {
+				return nil, fmt.Errorf("invalid auth entry for %q: expected \"username:password\"", key)
+			}
+			creds.Username = username
+			creds.Password = password
+		}
+
+		if auth.IdentityToken != "" {
+			creds.Password = auth.IdentityToken
+		}
+
+		if creds.Username == "" && creds.Password == "" {
+			// Entries like `"ghcr.io": {}` are placeholders left by a credsStore
+			continue
+		}
+		return &creds, nil
+	}
+
+	return nil, errDockerCredentialsNotFound
+}
+
+// runDockerCredentialHelper calls `docker-credential-<helper> get` following the docker credential helper protocol.
+func runDockerCredentialHelper(ctx context.Context, helper string, serverURL string) (*dockerCredentials, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// Helpers report missing credentials on stdout with a non-zero exit code
+		if strings.Contains(stdout.String(), "credentials not found") {
+			return nil, errDockerCredentialsNotFound
+		}
+		return nil, fmt.Errorf("docker-credential-%s failed: %w: %s", helper, err, strings.TrimSpace(stderr.String()+stdout.String()))
+	}
+
+	var resp struct {
+		ServerURL string `json:"ServerURL"`
+		Username  string `json:"Username"`
+		Secret    string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("unable to parse docker-credential-%s output: %w", helper, err)
+	}
+
+	if resp.ServerURL == "" {
+		resp.ServerURL = serverURL
+	}
+	return &dockerCredentials{ServerURL: resp.ServerURL, Username: resp.Username, Password: resp.Secret}, nil
+}
+
+// normalizeDockerServer converts docker config keys like "https://index.docker.io/v1/" into registry hostnames.
+func normalizeDockerServer(server string) string {
+	host := server
+	if _, rest, found := strings.Cut(host, "://"); found {
+		host = rest
+	}
+	host, _, _ = strings.Cut(host, "/")
+
+	if host == "index.docker.io" || host == "registry-1.docker.io" {
+		return dockerHubRegistry
+	}
+	return host
+}
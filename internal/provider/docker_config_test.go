@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeDockerConfig(t *testing.T, content string) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_CONFIG", dir)
+}
+
+// writeCredentialHelper installs a fake docker-credential-<name> executable on the PATH.
+func writeCredentialHelper(t *testing.T, name string, script string) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "docker-credential-"+name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestResolveDockerCredentials_auths(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("denvr:hunter2"))
+	writeDockerConfig(t, `{
+		"auths": {
+			"https://index.docker.io/v1/": {"auth": "`+auth+`"},
+			"ghcr.io": {"username": "octocat", "password": "ghp_secret"}
+		}
+	}`)
+
+	creds, err := resolveDockerCredentials(context.Background(), "docker.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.ServerURL != dockerHubServerURL || creds.Username != "denvr" || creds.Password != "hunter2" {
+		t.Errorf("unexpected docker hub credentials: %+v", creds)
+	}
+
+	creds, err = resolveDockerCredentials(context.Background(), "ghcr.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.ServerURL != "ghcr.io" || creds.Username != "octocat" || creds.Password != "ghp_secret" {
+		t.Errorf("unexpected ghcr.io credentials: %+v", creds)
+	}
+
+	_, err = resolveDockerCredentials(context.Background(), "quay.io")
+	if !errors.Is(err, errDockerCredentialsNotFound) {
+		t.Errorf("expected credentials not found error, got %v", err)
+	}
+}
+
+func TestResolveDockerCredentials_helpers(t *testing.T) {
+	writeCredentialHelper(t, "denvrtest", `read server
+if [ "$server" = "registry.example.com" ]; then
+	echo '{"ServerURL": "registry.example.com", "Username": "helper", "Secret": "s3cret"}'
+else
+	echo "credentials not found in native keychain"
+	exit 1
+fi
+`)
+	writeDockerConfig(t, `{
+		"auths": {"ghcr.io": {}, "quay.io": {"auth": "`+base64.StdEncoding.EncodeToString([]byte("quay:pass"))+`"}},
+		"credsStore": "denvrtest",
+		"credHelpers": {"ghcr.io": "denvrtest"}
+	}`)
+
+	creds, err := resolveDockerCredentials(context.Background(), "registry.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.Username != "helper" || creds.Password != "s3cret" {
+		t.Errorf("unexpected credsStore credentials: %+v", creds)
+	}
+
+	// Registry specific helpers don't fall back to the auths entries
+	if _, err := resolveDockerCredentials(context.Background(), "ghcr.io"); !errors.Is(err, errDockerCredentialsNotFound) {
+		t.Errorf("expected credentials not found error, got %v", err)
+	}
+
+	// The credsStore falls back to the auths entries
+	creds, err = resolveDockerCredentials(context.Background(), "quay.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.Username != "quay" || creds.Password != "pass" {
+		t.Errorf("unexpected auths credentials: %+v", creds)
+	}
+}
+
+func TestResolveDockerCredentials_dockerHubHelper(t *testing.T) {
+	writeCredentialHelper(t, "hubtest", `read server
+if [ "$server" = "https://index.docker.io/v1/" ]; then
+	echo '{"ServerURL": "https://index.docker.io/v1/", "Username": "hub", "Secret": "s3cret"}'
+else
+	echo "credentials not found in native keychain"
+	exit 1
+fi
+`)
+
+	// Docker writes either form of the Docker Hub key
+	for _, key := range []string{"https://index.docker.io/v1/", "index.docker.io"} {
+		t.Run(key, func(t *testing.T) {
+			writeDockerConfig(t, `{"credHelpers": {"`+key+`": "hubtest"}}`)
+
+			creds, err := resolveDockerCredentials(context.Background(), dockerHubRegistry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if creds.Username != "hub" || creds.Password != "s3cret" {
+				t.Errorf("unexpected docker hub credentials: %+v", creds)
+			}
+		})
+	}
+}
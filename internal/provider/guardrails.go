@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// guardrailPolicy limits what resources can be planned through the provider. Empty allow-lists
+// and a zero max_gpus_per_resource impose no limit.
+type guardrailPolicy struct {
+	AllowedClusters         []string
+	AllowedConfigurations   []string
+	AllowedHardwarePackages []string
+	AllowedResourcePools    []string
+	MaxGPUsPerResource      int
+}
+
+// GPU counts are part of the VM configuration and hardware package names,
+// e.g. "A100_40GB_SXM_8x" and "g-nvidia-8xa100-40gb-pcie-14vcpu-112gb"
+var (
+	configurationGPUsRegexp   = regexp.MustCompile(`_(\d+)x$`)
+	hardwarePackageGPUsRegexp = regexp.MustCompile(`^[a-z]+-[a-z]+-(\d+)x`)
+)
+
+// checkVM reports the policy violations of a planned VM. Values which are unknown or
+// unchanged from the prior state aren't checked, so tightening a policy doesn't block
+// updates to existing VMs. Each of the placement_preferences is checked like the placement itself.
+func (p *guardrailPolicy) checkVM(ctx context.Context, plan vmResourceModel, state *vmResourceModel, diags *diag.Diagnostics) {
+	if p == nil {
+		return
+	}
+	if state == nil {
+		state = &vmResourceModel{}
+	}
+
+	p.checkPlacement(path.Empty(), plan.placement(), state.placement(), diags)
+
+	prefs, d := plan.placementPreferences(ctx)
+	diags.Append(d...)
+	priors, d := state.placementPreferences(ctx)
+	diags.Append(d...)
+	priors = append(priors, state.placement())
+	for i, pref := range prefs {
+		prior := vmPlacementModel{}
+		if slices.Contains(priors, pref) {
+			prior = pref
+		}
+		p.checkPlacement(path.Root("placement_preferences").AtListIndex(i), pref, prior, diags)
+	}
+}
+
+// checkPlacement reports the policy violations of a VM placement, whose attributes are under parent.
+func (p *guardrailPolicy) checkPlacement(parent path.Path, plan, prior vmPlacementModel, diags *diag.Diagnostics) {
+	p.checkAllowed("allowed_clusters", p.AllowedClusters, parent.AtName("cluster"), plan.Cluster, prior.Cluster, diags)
+	p.checkAllowed("allowed_configurations", p.AllowedConfigurations, parent.AtName("configuration"), plan.Configuration, prior.Configuration, diags)
+	p.checkAllowed("allowed_resource_pools", p.AllowedResourcePools, parent.AtName("rpool"), plan.Rpool, prior.Rpool, diags)
+	p.checkGPUs(configurationGPUsRegexp, parent.AtName("configuration"), plan.Configuration, prior.Configuration, diags)
+}
+
+// checkApp reports the policy violations of a planned application, like checkVM.
+func (p *guardrailPolicy) checkApp(plan appResourceModel, state *appResourceModel, diags *diag.Diagnostics) {
+	if p == nil {
+		return
+	}
+	if state == nil {
+		state = &appResourceModel{}
+	}
+
+	p.checkAllowed("allowed_clusters", p.AllowedClusters, path.Root("cluster"), plan.Cluster, state.Cluster, diags)
+	p.checkAllowed("allowed_hardware_packages", p.AllowedHardwarePackages, path.Root("hardware_package_name"), plan.HardwarePackageName, state.HardwarePackageName, diags)
+	p.checkAllowed("allowed_resource_pools", p.AllowedResourcePools, path.Root("resource_pool"), plan.ResourcePool, state.ResourcePool, diags)
+	p.checkGPUs(hardwarePackageGPUsRegexp, path.Root("hardware_package_name"), plan.HardwarePackageName, state.HardwarePackageName, diags)
+}
+
+func (p *guardrailPolicy) checkAllowed(policy string, allowed []string, attr path.Path, value, prior types.String, diags *diag.Diagnostics) {
+	if len(allowed) == 0 || !isNewValue(value, prior) || slices.Contains(allowed, value.ValueString()) {
+		return
+	}
+
+	diags.AddAttributeError(
+		attr,
+		"Denvr Guardrail Policy Violation",
+		fmt.Sprintf("The provider's %s policy does not allow %s = %q. Allowed values: %s.",
+			policy, attr, value.ValueString(), strings.Join(allowed, ", ")),
+	)
+}
+
+func (p *guardrailPolicy) checkGPUs(gpusRegexp *regexp.Regexp, attr path.Path, value, prior types.String, diags *diag.Diagnostics) {
+	if p.MaxGPUsPerResource == 0 || !isNewValue(value, prior) {
+		return
+	}
+
+	gpus, ok := parseGPUCount(gpusRegexp, value.ValueString())
+	if !ok {
+		diags.AddAttributeError(
+			attr,
+			"Denvr Guardrail Policy Violation",
+			fmt.Sprintf("The provider's max_gpus_per_resource policy can't be checked because the number of GPUs in %s = %q is unknown.",
+				attr, value.ValueString()),
+		)
+		return
+	}
+	if gpus > p.MaxGPUsPerResource {
+		diags.AddAttributeError(
+			attr,
+			"Denvr Guardrail Policy Violation",
+			fmt.Sprintf("The provider's max_gpus_per_resource policy allows at most %d GPUs, but %s = %q has %d.",
+				p.MaxGPUsPerResource, attr, value.ValueString(), gpus),
+		)
+	}
+}
+
+// parseGPUCount returns the number of GPUs in a VM configuration or hardware package name.
+func parseGPUCount(gpusRegexp *regexp.Regexp, name string) (int, bool) {
+	match := gpusRegexp.FindStringSubmatch(strings.ToLower(name))
+	if match == nil {
+		return 0, false
+	}
+	gpus, err := strconv.Atoi(match[1])
+	return gpus, err == nil
+}
+
+// isNewValue returns true if a known, non-null planned value differs from the prior state.
+func isNewValue(value, prior types.String) bool {
+	return !value.IsNull() && !value.IsUnknown() && !value.Equal(prior)
+}
@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestGuardrailPolicy_checkVM(t *testing.T) {
+	policy := &guardrailPolicy{
+		AllowedClusters:       []string{"Msc1"},
+		AllowedConfigurations: []string{"A100_40GB_SXM_1x", "H100_80GB_SXM_8x"},
+		AllowedResourcePools:  []string{"on-demand"},
+		MaxGPUsPerResource:    4,
+	}
+	vm := func(cluster, configuration, rpool string) vmResourceModel {
+		return vmResourceModel{
+			Cluster:       types.StringValue(cluster),
+			Configuration: types.StringValue(configuration),
+			Rpool:         types.StringValue(rpool),
+		}
+	}
+
+	cases := []struct {
+		name   string
+		policy *guardrailPolicy
+		plan   vmResourceModel
+		state  *vmResourceModel
+		want   []string
+	}{
+		{"allowed", policy, vm("Msc1", "A100_40GB_SXM_1x", "on-demand"), nil, nil},
+		{"no policy", nil, vm("Hou1", "H100_80GB_SXM_8x", "reserved"), nil, nil},
+		{"empty policy", &guardrailPolicy{}, vm("Hou1", "H100_80GB_SXM_8x", "reserved"), nil, nil},
+		{"cluster", policy, vm("Hou1", "A100_40GB_SXM_1x", "on-demand"), nil, []string{"allowed_clusters cluster"}},
+		{"resource pool", policy, vm("Msc1", "A100_40GB_SXM_1x", "reserved"), nil, []string{"allowed_resource_pools rpool"}},
+		{
+			"configuration and gpus", policy, vm("Msc1", "A100_40GB_PCIe_8x", "on-demand"), nil,
+			[]string{"allowed_configurations configuration", "max_gpus_per_resource configuration"},
+		},
+		{"allowed configuration with too many gpus", policy, vm("Msc1", "H100_80GB_SXM_8x", "on-demand"), nil, []string{"max_gpus_per_resource configuration"}},
+		{"unchanged from state", policy, vm("Hou1", "H100_80GB_SXM_8x", "on-demand"), &vmResourceModel{
+			Cluster:       types.StringValue("Hou1"),
+			Configuration: types.StringValue("H100_80GB_SXM_8x"),
+		}, nil},
+		{"unknown", policy, vmResourceModel{Cluster: types.StringUnknown(), Configuration: types.StringUnknown(), Rpool: types.StringNull()}, nil, nil},
+		{
+			"placement preferences", policy, vmResourceModel{
+				PlacementPreferences: placementList(t, placement("Msc1", "A100_40GB_SXM_1x", "on-demand"), placement("Hou1", "A100_40GB_SXM_1x", "on-demand")),
+			}, nil,
+			[]string{"allowed_clusters placement_preferences[1].cluster"},
+		},
+		{
+			"placement preference unchanged from state", policy, vmResourceModel{
+				PlacementPreferences: placementList(t, placement("Hou1", "H100_80GB_SXM_8x", "on-demand")),
+			}, &vmResourceModel{
+				Cluster:       types.StringValue("Hou1"),
+				Configuration: types.StringValue("H100_80GB_SXM_8x"),
+				Rpool:         types.StringValue("on-demand"),
+			}, nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var diags diag.Diagnostics
+			c.policy.checkVM(context.Background(), c.plan, c.state, &diags)
+			assertPolicyViolations(t, diags, c.want)
+		})
+	}
+}
+
+func TestGuardrailPolicy_checkApp(t *testing.T) {
+	policy := &guardrailPolicy{
+		AllowedHardwarePackages: []string{"g-nvidia-1xa100-40gb-pcie-14vcpu-112gb", "g-nvidia-8xh100-80gb-sxm-208vcpu-1800gb"},
+		MaxGPUsPerResource:      2,
+	}
+	app := func(hardwarePackage string) appResourceModel {
+		return appResourceModel{
+			Cluster:             types.StringValue("Msc1"),
+			HardwarePackageName: types.StringValue(hardwarePackage),
+			ResourcePool:        types.StringValue("on-demand"),
+		}
+	}
+
+	cases := []struct {
+		name string
+		plan appResourceModel
+		want []string
+	}{
+		{"allowed", app("g-nvidia-1xa100-40gb-pcie-14vcpu-112gb"), nil},
+		{"too many gpus", app("g-nvidia-8xh100-80gb-sxm-208vcpu-1800gb"), []string{"max_gpus_per_resource hardware_package_name"}},
+		{
+			"unknown gpu count", app("cpu-only-16vcpu"),
+			[]string{"allowed_hardware_packages hardware_package_name", "max_gpus_per_resource hardware_package_name"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var diags diag.Diagnostics
+			policy.checkApp(c.plan, nil, &diags)
+			assertPolicyViolations(t, diags, c.want)
+		})
+	}
+}
+
+func TestParseGPUCount(t *testing.T) {
+	cases := []struct {
+		name   string
+		want   int
+		wantOk bool
+	}{
+		{"A100_40GB_SXM_1x", 1, true},
+		{"H100_80GB_SXM_8x", 8, true},
+		{"g-nvidia-4xa100-40gb-pcie-56vcpu-448gb", 4, true},
+		{"custom", 0, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gpusRegexp := configurationGPUsRegexp
+			if strings.HasPrefix(c.name, "g-") {
+				gpusRegexp = hardwarePackageGPUsRegexp
+			}
+			if got, ok := parseGPUCount(gpusRegexp, c.name); got != c.want || ok != c.wantOk {
+				t.Errorf("expected %d, %t, got %d, %t", c.want, c.wantOk, got, ok)
+			}
+		})
+	}
+}
+
+// assertPolicyViolations checks diags has one error per "<policy> <attribute>" in want.
+func assertPolicyViolations(t *testing.T, diags diag.Diagnostics, want []string) {
+	t.Helper()
+
+	errs := diags.Errors()
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d violations, got %v", len(want), diags)
+	}
+	for i, violation := range want {
+		policy, attr, _ := strings.Cut(violation, " ")
+		withPath, ok := errs[i].(diag.DiagnosticWithPath)
+		if !ok || withPath.Path().String() != attr || !strings.Contains(errs[i].Detail(), policy) {
+			t.Errorf("expected a %s violation on %s, got %v", policy, attr, errs[i])
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// dockerHubRegistry is the registry implied by image references without an explicit hostname.
+const dockerHubRegistry = "docker.io"
+
+// Regular expressions for the OCI distribution reference grammar
+// https://github.com/distribution/reference/blob/main/reference.go
+var (
+	imagePathComponentRegexp = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]+)[a-z0-9]+)*$`)
+	imageDomainRegexp        = regexp.MustCompile(`^(?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*|\[[a-fA-F0-9:]+\])(?::[0-9]+)?$`)
+	imageTagRegexp           = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	imageDigestRegexp        = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-fA-F0-9]{32,}$`)
+)
+
+// imageReferenceNameMaxChars is the maximum length of the domain and path of a reference.
+const imageReferenceNameMaxChars = 255
+
+// imageReference is a parsed container image reference (e.g. "ghcr.io/org/img:tag@sha256:...").
+type imageReference struct {
+	Domain string
+	Path   string
+	Tag    string
+	Digest string
+}
+
+// parseImageReference parses an image reference using the same rules as docker, so
+// "karthequian/helloworld:latest" resolves to the "docker.io" domain.
+func parseImageReference(s string) (imageReference, error) {
+	var ref imageReference
+	if s == "" {
+		return ref, fmt.Errorf("image reference is empty")
+	}
+
+	name := s
+	if before, after, found := strings.Cut(name, "@"); found {
+		if !imageDigestRegexp.MatchString(after) {
+			return ref, fmt.Errorf("invalid digest %q", after)
+		}
+		name, ref.Digest = before, after
+	}
+
+	// A ':' after the last '/' separates the tag, otherwise it's part of the domain port
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		if !imageTagRegexp.MatchString(name[i+1:]) {
+			return ref, fmt.Errorf("invalid tag %q", name[i+1:])
+		}
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+
+	if len(name) > imageReferenceNameMaxChars {
+		return ref, fmt.Errorf("repository name must not be more than %d characters", imageReferenceNameMaxChars)
+	}
+
+	// Like docker, the first path component is only treated as a domain if it
+	// contains a "." or ":", is "localhost" or contains uppercase characters.
+	ref.Domain, ref.Path = dockerHubRegistry, name
+	if first, rest, found := strings.Cut(name, "/"); found &&
+		(strings.ContainsAny(first, ".:") || first == "localhost" || strings.ToLower(first) != first) {
+		if !imageDomainRegexp.MatchString(first) {
+			return ref, fmt.Errorf("invalid registry hostname %q", first)
+		}
+		ref.Domain, ref.Path = first, rest
+	}
+
+	if ref.Domain == "index.docker.io" || ref.Domain == "registry-1.docker.io" {
+		ref.Domain = dockerHubRegistry
+	}
+
+	for _, component := range strings.Split(ref.Path, "/") {
+		if !imagePathComponentRegexp.MatchString(component) {
+			return ref, fmt.Errorf("invalid repository path component %q, must be lowercase alphanumeric separated by '.', '_', '__' or '-'", component)
+		}
+	}
+
+	return ref, nil
+}
+
+// Repository returns the repository path used by the registry API,
+// which includes the implicit "library/" prefix for official Docker Hub images.
+func (r imageReference) Repository() string {
+	if r.Domain == dockerHubRegistry && !strings.Contains(r.Path, "/") {
+		return "library/" + r.Path
+	}
+	return r.Path
+}
+
+// WithDigest returns the reference pinned to a manifest digest (e.g. "karthequian/helloworld@sha256:...").
+// Docker Hub references are returned without a domain, matching how they're usually written.
+func (r imageReference) WithDigest(digest string) string {
+	if r.Domain == dockerHubRegistry {
+		return r.Path + "@" + digest
+	}
+	return r.Domain + "/" + r.Path + "@" + digest
+}
+
+// RegistryHostname returns the hostname sent as the image repository hostname,
+// using the docker config key for Docker Hub.
+func (r imageReference) RegistryHostname() string {
+	if r.Domain == dockerHubRegistry {
+		return dockerHubServerURL
+	}
+	return r.Domain
+}
+
+// imageRegistryHost returns the registry hostname of an image reference
+// (e.g. "ghcr.io/org/img:tag" => "ghcr.io" and "karthequian/helloworld:latest" => "docker.io").
+func imageRegistryHost(imageUrl string) string {
+	ref, err := parseImageReference(imageUrl)
+	if err != nil {
+		return ""
+	}
+	return ref.Domain
+}
+
+var (
+	_ validator.String    = imageReferenceValidator{}
+	_ planmodifier.String = imageRepositoryHostnameModifier{}
+)
+
+// imageReferenceValidator validates that a string is a valid container image reference.
+type imageReferenceValidator struct{}
+
+func (v imageReferenceValidator) Description(ctx context.Context) string {
+	return v.MarkdownDescription(ctx)
+}
+
+func (v imageReferenceValidator) MarkdownDescription(_ context.Context) string {
+	return "value must be a valid container image reference"
+}
+
+func (v imageReferenceValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	if _, err := parseImageReference(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid Image Reference",
+			fmt.Sprintf("%q is not a valid container image reference: %s", req.ConfigValue.ValueString(), err),
+		)
+	}
+}
+
+// imageRepositoryHostnameModifier defaults image_repository_hostname to the registry of image_url when it isn't configured.
+type imageRepositoryHostnameModifier struct{}
+
+func (m imageRepositoryHostnameModifier) Description(ctx context.Context) string {
+	return m.MarkdownDescription(ctx)
+}
+
+func (m imageRepositoryHostnameModifier) MarkdownDescription(_ context.Context) string {
+	return "Defaults to the registry hostname of image_url."
+}
+
+func (m imageRepositoryHostnameModifier) PlanModifyString(ctx context.Context, req planmodifier.StringRequest, resp *planmodifier.StringResponse) {
+	if !req.ConfigValue.IsNull() {
+		return
+	}
+
+	var imageUrl types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("image_url"), &imageUrl)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if imageUrl.IsUnknown() {
+		resp.PlanValue = types.StringUnknown()
+		return
+	} else if imageUrl.IsNull() {
+		// Catalog applications don't have an image repository
+		resp.PlanValue = types.StringNull()
+		return
+	}
+
+	// Invalid references are reported by the image_url validator
+	ref, err := parseImageReference(imageUrl.ValueString())
+	if err != nil {
+		return
+	}
+	resp.PlanValue = types.StringValue(ref.RegistryHostname())
+}
@@ -0,0 +1,83 @@
+package provider
+
+import "testing"
+
+func TestParseImageReference(t *testing.T) {
+	digest := "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	cases := map[string]imageReference{
+		"nginx":                          {Domain: "docker.io", Path: "nginx"},
+		"karthequian/helloworld:latest":  {Domain: "docker.io", Path: "karthequian/helloworld", Tag: "latest"},
+		"docker.io/library/nginx:1.27":   {Domain: "docker.io", Path: "library/nginx", Tag: "1.27"},
+		"index.docker.io/library/nginx":  {Domain: "docker.io", Path: "library/nginx"},
+		"ghcr.io/org/img:tag":            {Domain: "ghcr.io", Path: "org/img", Tag: "tag"},
+		"localhost/img":                  {Domain: "localhost", Path: "img"},
+		"localhost:5000/org/img:tag":     {Domain: "localhost:5000", Path: "org/img", Tag: "tag"},
+		"registry.example.com:443/a/b/c": {Domain: "registry.example.com:443", Path: "a/b/c"},
+		"ghcr.io/org/img@" + digest:      {Domain: "ghcr.io", Path: "org/img", Digest: digest},
+		"ghcr.io/org/img:v1@" + digest:   {Domain: "ghcr.io", Path: "org/img", Tag: "v1", Digest: digest},
+	}
+
+	for s, expected := range cases {
+		actual, err := parseImageReference(s)
+		if err != nil {
+			t.Errorf("parseImageReference(%q) returned error: %v", s, err)
+		} else if actual != expected {
+			t.Errorf("parseImageReference(%q) = %+v, expected %+v", s, actual, expected)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"karthequian/HelloWorld",
+		"ghcr.io/Org/img",
+		"ghcr.io//img",
+		"ghcr.io/org/img:",
+		"ghcr.io/org/img:-tag",
+		"ghcr.io/org/img@sha256:abc",
+		"https://ghcr.io/org/img",
+	}
+
+	for _, s := range invalid {
+		if ref, err := parseImageReference(s); err == nil {
+			t.Errorf("parseImageReference(%q) = %+v, expected an error", s, ref)
+		}
+	}
+}
+
+func TestImageReferenceRepository(t *testing.T) {
+	cases := map[string]string{
+		"nginx":                  "library/nginx",
+		"karthequian/helloworld": "karthequian/helloworld",
+		"ghcr.io/org/img":        "org/img",
+		"localhost:5000/img":     "img",
+	}
+
+	for s, expected := range cases {
+		ref, err := parseImageReference(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := ref.Repository(); actual != expected {
+			t.Errorf("Repository() of %q = %q, expected %q", s, actual, expected)
+		}
+	}
+}
+
+func TestImageReferenceWithDigest(t *testing.T) {
+	digest := "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	cases := map[string]string{
+		"karthequian/helloworld:latest": "karthequian/helloworld@" + digest,
+		"docker.io/library/nginx":       "library/nginx@" + digest,
+		"ghcr.io/org/img:tag":           "ghcr.io/org/img@" + digest,
+	}
+
+	for s, expected := range cases {
+		ref, err := parseImageReference(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := ref.WithDigest(digest); actual != expected {
+			t.Errorf("WithDigest() of %q = %q, expected %q", s, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/time/rate"
+)
+
+// requestLimiter caps the number of in-flight API requests and the request rate. A single limiter
+// is shared by every resource through the provider's client, so it also covers polling from wait loops.
+type requestLimiter struct {
+	// slots holds one token per in-flight request, nil when concurrency isn't limited
+	slots chan struct{}
+
+	// rate is nil when the request rate isn't limited
+	rate *rate.Limiter
+}
+
+// newRequestLimiter returns a limiter for maxConcurrent in-flight requests and requestsPerSecond,
+// where zero disables the respective limit.
+func newRequestLimiter(maxConcurrent int, requestsPerSecond float64) *requestLimiter {
+	l := &requestLimiter{}
+	if maxConcurrent > 0 {
+		l.slots = make(chan struct{}, maxConcurrent)
+	}
+	if requestsPerSecond > 0 {
+		l.rate = rate.NewLimiter(rate.Limit(requestsPerSecond), 1)
+	}
+	return l
+}
+
+// acquire blocks until a request may be sent and returns a function to release its slot.
+func (l *requestLimiter) acquire(ctx context.Context) (func(), error) {
+	release := func() {}
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			tflog.Debug(ctx, "Waiting for a free Denvr API request slot")
+			select {
+			case l.slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		var once sync.Once
+		release = func() { once.Do(func() { <-l.slots }) }
+	}
+
+	if l.rate != nil {
+		if err := l.rate.Wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	return release, nil
+}
+
+// limitTransport holds a limiter slot from sending a request until its response body is closed.
+type limitTransport struct {
+	base    http.RoundTripper
+	limiter *requestLimiter
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.limiter.acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseOnClose) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimitTransport_concurrency(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &limitTransport{base: http.DefaultTransport, limiter: newRequestLimiter(3, 0)}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if peak.Load() > 3 {
+		t.Errorf("expected at most 3 requests in flight, got %d", peak.Load())
+	}
+}
+
+func TestLimitTransport_rate(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	client := &http.Client{Transport: &limitTransport{base: http.DefaultTransport, limiter: newRequestLimiter(0, 50)}}
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// The first request is sent immediately and the next five are spaced 20ms apart
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected 6 requests at 50/s to take at least 100ms, took %s", elapsed)
+	}
+}
+
+func TestRequestLimiter_cancel(t *testing.T) {
+	limiter := newRequestLimiter(1, 0)
+	release, err := limiter.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.acquire(ctx); err == nil {
+		t.Error("expected acquire to fail while the only slot is held")
+	}
+
+	// Releasing twice must not free a slot held by someone else
+	release()
+	release()
+	if _, err := limiter.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := limiter.acquire(ctx); err == nil {
+		t.Error("expected the slot to be held again")
+	}
+}
@@ -0,0 +1,125 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// vmPlacementModel is where a VM is created: an entry of placement_preferences, or the
+// cluster, configuration and rpool of the VM itself.
+type vmPlacementModel struct {
+	Cluster       types.String `tfsdk:"cluster"`
+	Configuration types.String `tfsdk:"configuration"`
+	Rpool         types.String `tfsdk:"rpool"`
+}
+
+func (p vmPlacementModel) String() string {
+	return fmt.Sprintf("%s in cluster %s resource pool %s", p.Configuration.ValueString(), p.Cluster.ValueString(), p.Rpool.ValueString())
+}
+
+func placementPreferencesAttribute() schema.ListNestedAttribute {
+	return schema.ListNestedAttribute{
+		Optional: true,
+		MarkdownDescription: "Places the VM in the first of these clusters, configurations and resource pools with capacity, " +
+			"instead of setting `cluster`, `configuration` and `rpool`. Preferences with nothing available are skipped. " +
+			"The chosen placement is recorded in `cluster`, `configuration` and `rpool`, and the VM is only replaced " +
+			"when its placement is removed from the list.",
+		NestedObject: schema.NestedAttributeObject{
+			Attributes: map[string]schema.Attribute{
+				"cluster": schema.StringAttribute{
+					Required: true,
+				},
+				"configuration": schema.StringAttribute{
+					Required: true,
+				},
+				"rpool": schema.StringAttribute{
+					Required: true,
+				},
+			},
+		},
+		Validators: []validator.List{
+			listvalidator.SizeAtLeast(1),
+		},
+	}
+}
+
+// placement returns where the VM is, or is planned to be, placed.
+func (m vmResourceModel) placement() vmPlacementModel {
+	return vmPlacementModel{Cluster: m.Cluster, Configuration: m.Configuration, Rpool: m.Rpool}
+}
+
+// placementPreferences returns the placement_preferences of the VM, or nil if they aren't set or known.
+func (m vmResourceModel) placementPreferences(ctx context.Context) ([]vmPlacementModel, diag.Diagnostics) {
+	if m.PlacementPreferences.IsNull() || m.PlacementPreferences.IsUnknown() {
+		return nil, nil
+	}
+
+	var prefs []vmPlacementModel
+	diags := m.PlacementPreferences.ElementsAs(ctx, &prefs, false)
+	return prefs, diags
+}
+
+// planPlacement plans the cluster, configuration and rpool of a VM with placement_preferences.
+// An existing VM keeps its placement while that is still one of the preferences, and is otherwise
+// replaced by one placed during apply.
+func planPlacement(ctx context.Context, plan *vmResourceModel, state *vmResourceModel, resp *resource.ModifyPlanResponse) {
+	if plan.PlacementPreferences.IsNull() {
+		return
+	}
+
+	prefs, diags := plan.placementPreferences(ctx)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	if state != nil {
+		for _, pref := range prefs {
+			if pref == state.placement() {
+				plan.Cluster, plan.Configuration, plan.Rpool = state.Cluster, state.Configuration, state.Rpool
+				return
+			}
+		}
+		tflog.Debug(ctx, fmt.Sprintf("VM placement %s is no longer in placement_preferences", state.placement()))
+		resp.RequiresReplace = append(resp.RequiresReplace, path.Root("placement_preferences"))
+	}
+
+	plan.Cluster, plan.Configuration, plan.Rpool = types.StringUnknown(), types.StringUnknown(), types.StringUnknown()
+}
+
+// placeVM calls create with each placement in turn until one doesn't fail for lack of capacity,
+// and returns the placement it was called with. A non-nil available is checked first, and
+// placements with nothing available are skipped.
+func placeVM(ctx context.Context, placements []vmPlacementModel, available func(context.Context, vmPlacementModel) (bool, error), create func(vmPlacementModel) error) (vmPlacementModel, error) {
+	err := errors.New("no placements to try")
+	for _, p := range placements {
+		if available != nil {
+			// Try the create when availability can't be checked, rather than skipping a placement which may have room
+			ok, availErr := available(ctx, p)
+			if availErr != nil {
+				tflog.Warn(ctx, "Unable to check Denvr capacity: "+availErr.Error())
+			} else if !ok {
+				tflog.Info(ctx, "Skipping VM placement with no capacity: "+p.String())
+				err = fmt.Errorf("no available capacity for %s", p)
+				continue
+			}
+		}
+
+		err = create(p)
+		if !isCapacityError(err) {
+			return p, err
+		}
+		tflog.Info(ctx, fmt.Sprintf("Unable to create VM as %s: %s", p, err))
+	}
+	return vmPlacementModel{}, err
+}
@@ -0,0 +1,140 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestPlanPlacement(t *testing.T) {
+	msc1 := placement("Msc1", "A100_40GB_SXM_8x", "on-demand")
+	hou1 := placement("Hou1", "A100_40GB_PCIe_8x", "on-demand")
+	existing := func(p vmPlacementModel) *vmResourceModel {
+		return &vmResourceModel{Cluster: p.Cluster, Configuration: p.Configuration, Rpool: p.Rpool}
+	}
+
+	cases := []struct {
+		name        string
+		prefs       types.List
+		state       *vmResourceModel
+		want        vmPlacementModel
+		wantReplace bool
+	}{
+		{"new", placementList(t, msc1, hou1), nil, vmPlacementModel{}, false},
+		{"kept", placementList(t, msc1, hou1), existing(hou1), hou1, false},
+		{"reordered", placementList(t, hou1, msc1), existing(msc1), msc1, false},
+		{"removed", placementList(t, hou1), existing(msc1), vmPlacementModel{}, true},
+		{"unknown", types.ListUnknown(placementElemType()), existing(msc1), vmPlacementModel{}, true},
+		{"not set", types.ListNull(placementElemType()), existing(msc1), msc1, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			plan := vmResourceModel{PlacementPreferences: c.prefs}
+			if c.prefs.IsNull() {
+				plan.Cluster, plan.Configuration, plan.Rpool = msc1.Cluster, msc1.Configuration, msc1.Rpool
+			}
+			resp := &resource.ModifyPlanResponse{}
+			planPlacement(context.Background(), &plan, c.state, resp)
+
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected error: %v", resp.Diagnostics)
+			}
+			want := c.want
+			if want == (vmPlacementModel{}) {
+				want = vmPlacementModel{Cluster: types.StringUnknown(), Configuration: types.StringUnknown(), Rpool: types.StringUnknown()}
+			}
+			if plan.placement() != want {
+				t.Errorf("expected placement %v, got %v", want, plan.placement())
+			}
+			if replace := len(resp.RequiresReplace) > 0; replace != c.wantReplace {
+				t.Errorf("expected requires replace %t, got %v", c.wantReplace, resp.RequiresReplace)
+			} else if replace && !resp.RequiresReplace[0].Equal(path.Root("placement_preferences")) {
+				t.Errorf("expected placement_preferences to require replacement, got %v", resp.RequiresReplace)
+			}
+		})
+	}
+}
+
+func TestPlaceVM(t *testing.T) {
+	msc1 := placement("Msc1", "A100_40GB_SXM_8x", "on-demand")
+	hou1 := placement("Hou1", "A100_40GB_PCIe_8x", "on-demand")
+	errCapacity := errors.New("create server failed: 400 Bad Request: Insufficient capacity in cluster")
+	errOther := errors.New("create server failed: 400 Bad Request: invalid vpc")
+
+	cases := []struct {
+		name      string
+		available map[string]bool
+		availErr  error
+		noCheck   bool
+		creates   map[string]error
+		want      vmPlacementModel
+		wantTried []string
+		wantErr   string
+	}{
+		{"first", map[string]bool{"Msc1": true, "Hou1": true}, nil, false, nil, msc1, []string{"Msc1"}, ""},
+		{"skips unavailable", map[string]bool{"Hou1": true}, nil, false, nil, hou1, []string{"Hou1"}, ""},
+		{"next on capacity error", map[string]bool{"Msc1": true, "Hou1": true}, nil, false, map[string]error{"Msc1": errCapacity}, hou1, []string{"Msc1", "Hou1"}, ""},
+		{"stops on other errors", map[string]bool{"Msc1": true, "Hou1": true}, nil, false, map[string]error{"Msc1": errOther}, msc1, []string{"Msc1"}, "invalid vpc"},
+		{"none available", nil, nil, false, nil, vmPlacementModel{}, nil, "no available capacity for A100_40GB_PCIe_8x in cluster Hou1"},
+		{"availability unknown", nil, errors.New("timeout"), false, nil, msc1, []string{"Msc1"}, ""},
+		{"no availability check", nil, nil, true, map[string]error{"Msc1": errCapacity, "Hou1": errCapacity}, vmPlacementModel{}, []string{"Msc1", "Hou1"}, "Insufficient capacity"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			available := func(_ context.Context, p vmPlacementModel) (bool, error) {
+				return c.available[p.Cluster.ValueString()], c.availErr
+			}
+			if c.noCheck {
+				available = nil
+			}
+			var tried []string
+			create := func(p vmPlacementModel) error {
+				tried = append(tried, p.Cluster.ValueString())
+				return c.creates[p.Cluster.ValueString()]
+			}
+
+			got, err := placeVM(context.Background(), []vmPlacementModel{msc1, hou1}, available, create)
+			if c.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+				t.Fatalf("expected an error containing %q, got %v", c.wantErr, err)
+			}
+			if c.wantErr == "" && got != c.want {
+				t.Errorf("expected placement %v, got %v", c.want, got)
+			}
+			if strings.Join(tried, ",") != strings.Join(c.wantTried, ",") {
+				t.Errorf("expected creates in %v, got %v", c.wantTried, tried)
+			}
+		})
+	}
+}
+
+func placement(cluster, configuration, rpool string) vmPlacementModel {
+	return vmPlacementModel{
+		Cluster:       types.StringValue(cluster),
+		Configuration: types.StringValue(configuration),
+		Rpool:         types.StringValue(rpool),
+	}
+}
+
+func placementElemType() types.ObjectType {
+	return placementPreferencesAttribute().GetType().(types.ListType).ElemType.(types.ObjectType)
+}
+
+// placementList builds a placement_preferences value from placements.
+func placementList(t *testing.T, placements ...vmPlacementModel) types.List {
+	t.Helper()
+
+	list, diags := types.ListValueFrom(context.Background(), placementElemType(), placements)
+	if diags.HasError() {
+		t.Fatalf("unable to build placement_preferences: %v", diags)
+	}
+	return list
+}
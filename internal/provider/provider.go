@@ -2,28 +2,393 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/float64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 var _ provider.Provider = (*denvrProvider)(nil)
 
-func New() func() provider.Provider {
+func New(version string) func() provider.Provider {
 	return func() provider.Provider {
-		return &denvrProvider{}
+		return &denvrProvider{version: version}
 	}
 }
 
-type denvrProvider struct{}
+type denvrProvider struct {
+	// version is the provider release, "dev" for local builds and "test" in acceptance tests
+	version string
+}
 
-func (p *denvrProvider) Schema(ctx context.Context, req provider.SchemaRequest, resp *provider.SchemaResponse) {
+type denvrProviderModel struct {
+	AllowedClusters           types.Set     `tfsdk:"allowed_clusters"`
+	AllowedConfigurations     types.Set     `tfsdk:"allowed_configurations"`
+	AllowedHardwarePackages   types.Set     `tfsdk:"allowed_hardware_packages"`
+	AllowedResourcePools      types.Set     `tfsdk:"allowed_resource_pools"`
+	AuditLogFile              types.String  `tfsdk:"audit_log_file"`
+	CABundleFile              types.String  `tfsdk:"ca_bundle_file"`
+	ClientCertificate         types.String  `tfsdk:"client_certificate"`
+	ClientKey                 types.String  `tfsdk:"client_key"`
+	CredentialProcess         types.String  `tfsdk:"credential_process"`
+	HTTPProxy                 types.String  `tfsdk:"http_proxy"`
+	InsecureSkipVerify        types.Bool    `tfsdk:"insecure_skip_verify"`
+	MaxConcurrentRequests     types.Int64   `tfsdk:"max_concurrent_requests"`
+	MaxGPUsPerResource        types.Int64   `tfsdk:"max_gpus_per_resource"`
+	MaxRetries                types.Int64   `tfsdk:"max_retries"`
+	Password                  types.String  `tfsdk:"password"`
+	Profile                   types.String  `tfsdk:"profile"`
+	ReadOnly                  types.Bool    `tfsdk:"read_only"`
+	RequestsPerSecond         types.Float64 `tfsdk:"requests_per_second"`
+	RetryMaxWait              types.Int64   `tfsdk:"retry_max_wait"`
+	Server                    types.String  `tfsdk:"server"`
+	SkipCredentialsValidation types.Bool    `tfsdk:"skip_credentials_validation"`
+	StrictCapacityCheck       types.Bool    `tfsdk:"strict_capacity_check"`
+	Username                  types.String  `tfsdk:"username"`
+}
 
+func (p *denvrProvider) Schema(ctx context.Context, req provider.SchemaRequest, resp *provider.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"allowed_clusters": schema.SetAttribute{
+				Optional:            true,
+				ElementType:         types.StringType,
+				MarkdownDescription: "Clusters which VMs and applications may be created in. Defaults to any cluster.",
+				Validators:          []validator.Set{setvalidator.SizeAtLeast(1)},
+			},
+			"allowed_configurations": schema.SetAttribute{
+				Optional:            true,
+				ElementType:         types.StringType,
+				MarkdownDescription: "VM `configuration`s which may be used, e.g. `[\"A100_40GB_SXM_1x\"]`. Defaults to any configuration.",
+				Validators:          []validator.Set{setvalidator.SizeAtLeast(1)},
+			},
+			"allowed_hardware_packages": schema.SetAttribute{
+				Optional:            true,
+				ElementType:         types.StringType,
+				MarkdownDescription: "Application `hardware_package_name`s which may be used. Defaults to any hardware package.",
+				Validators:          []validator.Set{setvalidator.SizeAtLeast(1)},
+			},
+			"allowed_resource_pools": schema.SetAttribute{
+				Optional:            true,
+				ElementType:         types.StringType,
+				MarkdownDescription: "Resource pools (`rpool` of VMs and `resource_pool` of applications) which may be used. Defaults to any resource pool.",
+				Validators:          []validator.Set{setvalidator.SizeAtLeast(1)},
+			},
+			"audit_log_file": schema.StringAttribute{
+				Optional: true,
+				MarkdownDescription: "Path of a file to append a JSON line to for every call which creates or destroys Denvr resources. " +
+					"Each line has the time, the authenticated user, the operation, the resource type, the resource as `<cluster>/<name>`, " +
+					"a summary of the request without secrets, and the ID and status returned. Terraform doesn't give providers the resource address, " +
+					"e.g. `denvr_vm.foo[3]`, so the cluster and name are recorded instead.",
+			},
+			"ca_bundle_file": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Path to a PEM file of CA certificates to trust in addition to the system roots, e.g. for a proxy which re-signs TLS.",
+			},
+			"client_certificate": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "PEM encoded client certificate, or the path to one, for mutual TLS. Requires `client_key`.",
+				Validators: []validator.String{
+					stringvalidator.AlsoRequires(path.MatchRoot("client_key")),
+				},
+			},
+			"client_key": schema.StringAttribute{
+				Optional:            true,
+				Sensitive:           true,
+				MarkdownDescription: "PEM encoded private key of `client_certificate`, or the path to one.",
+				Validators: []validator.String{
+					stringvalidator.AlsoRequires(path.MatchRoot("client_certificate")),
+				},
+			},
+			"credential_process": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Command which prints the credentials as JSON (e.g. `{\"username\": \"...\", \"password\": \"...\", \"server\": \"...\"}`). Used when neither the provider attributes nor the `DENVR_USERNAME` and `DENVR_PASSWORD` environment variables are set.",
+			},
+			"http_proxy": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "URL of the proxy for all requests, e.g. `http://proxy.example.com:3128`. Defaults to the `HTTPS_PROXY`, `HTTP_PROXY` and `NO_PROXY` environment variables.",
+			},
+			"insecure_skip_verify": schema.BoolAttribute{
+				Optional:            true,
+				MarkdownDescription: "Skip verifying the TLS certificate of the server. Only use this for testing, prefer `ca_bundle_file`. Defaults to `false`.",
+			},
+			"max_concurrent_requests": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Maximum number of API requests in flight at once, shared by all resources using this provider. Unlimited by default.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"max_gpus_per_resource": schema.Int64Attribute{
+				Optional: true,
+				MarkdownDescription: "Maximum number of GPUs in a single VM or application, going by the GPU count in its `configuration` or `hardware_package_name` " +
+					"(e.g. `A100_40GB_SXM_8x` has 8). Defaults to no limit.",
+				Validators: []validator.Int64{int64validator.AtLeast(1)},
+			},
+			"max_retries": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Maximum number of times a request is retried after a connection error, `429` or `5xx` response. Only idempotent requests are retried after a `5xx` response. Defaults to `retries` in the config file, or `5`.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"password": schema.StringAttribute{
+				Optional:            true,
+				Sensitive:           true,
+				MarkdownDescription: "Password for the Denvr Cloud account. May also be set with `DENVR_PASSWORD`.",
+			},
+			"profile": schema.StringAttribute{
+				Optional: true,
+				MarkdownDescription: "Name of a `[profile.<name>]` section in the config file to read credentials from instead of `[credentials]`. " +
+					"When set here, the profile takes precedence over the `DENVR_USERNAME` and `DENVR_PASSWORD` environment variables. May also be set with `DENVR_PROFILE`.",
+			},
+			"read_only": schema.BoolAttribute{
+				Optional: true,
+				MarkdownDescription: "Refuse to create, update or delete resources, for plan-only pipelines. " +
+					"Plans, refreshes and imports still work, and any other API request which could change resources is rejected before it is sent. Defaults to `false`.",
+			},
+			"requests_per_second": schema.Float64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Maximum number of API requests per second, shared by all resources using this provider and including polling while waiting for resources to come online. Unlimited by default.",
+				Validators: []validator.Float64{
+					float64validator.AtLeast(0.1),
+				},
+			},
+			"retry_max_wait": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Maximum number of seconds to wait between retries, including waits requested by a `Retry-After` header. Defaults to `30`.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"server": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Denvr Cloud API endpoint. May also be set with `DENVR_SERVER`. Defaults to `" + defaultServer + "`.",
+			},
+			"skip_credentials_validation": schema.BoolAttribute{
+				Optional:            true,
+				MarkdownDescription: "Skip authenticating with the API when the provider is configured, for offline `validate` and `plan` jobs. Missing credentials are then reported as a warning. Defaults to `false`.",
+			},
+			"strict_capacity_check": schema.BoolAttribute{
+				Optional: true,
+				MarkdownDescription: "Fail plans which create VMs and applications with more GPUs than the cluster and resource pool have free, " +
+					"rather than warning. Defaults to `false`.",
+			},
+			"username": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Username or email address for the Denvr Cloud account. May also be set with `DENVR_USERNAME`.",
+			},
+		},
+	}
 }
 
 func (p *denvrProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	var data denvrProviderModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Credentials which come from other resources (e.g. a secrets manager) may not be known until apply
+	if unknown := data.unknownAttributes(); len(unknown) > 0 {
+		if req.ClientCapabilities.DeferralAllowed {
+			tflog.Debug(ctx, "Deferring Denvr resources until the provider configuration is known: "+strings.Join(unknown, ", "))
+			resp.Deferred = &provider.Deferred{Reason: provider.DeferredReasonProviderConfigUnknown}
+			return
+		}
+
+		resp.Diagnostics.AddError(
+			"Unknown Denvr Provider Configuration",
+			fmt.Sprintf("The denvr provider attributes %s depend on values that are not known until apply. ", strings.Join(unknown, ", "))+
+				"Run Terraform 1.9 or later with -allow-deferral, or apply the resources the provider configuration depends on first with -target.",
+		)
+		return
+	}
+
+	configPath := denvrConfigPath()
+	creds, err := resolveCredentials(ctx, data.credentialSources(configPath))
+	if err != nil && data.SkipCredentialsValidation.ValueBool() {
+		// Offline validate and plan jobs may have no credentials at all, so any request will fail instead
+		resp.Diagnostics.AddWarning(
+			"Unable to Resolve Denvr Credentials",
+			err.Error()+"\n\nskip_credentials_validation is set, so the provider is configured without credentials "+
+				"and any request to the Denvr API will fail to authenticate.",
+		)
+	} else if err != nil {
+		resp.Diagnostics.AddError(
+			"Unable to Resolve Denvr Credentials",
+			err.Error(),
+		)
+		return
+	}
+	tflog.Debug(ctx, "Configured Denvr provider for "+creds.Server+" with credentials from "+creds.Source)
+
+	transport, err := newTransport(transportOptions{
+		HTTPProxy:          data.HTTPProxy.ValueString(),
+		CABundleFile:       data.CABundleFile.ValueString(),
+		ClientCertificate:  data.ClientCertificate.ValueString(),
+		ClientKey:          data.ClientKey.ValueString(),
+		InsecureSkipVerify: data.InsecureSkipVerify.ValueBool(),
+		UserAgent:          userAgent(p.version, req.TerraformVersion),
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid Denvr Provider Transport Configuration", err.Error())
+		return
+	}
+	if data.InsecureSkipVerify.ValueBool() {
+		tflog.Warn(ctx, "TLS certificate verification is disabled by insecure_skip_verify")
+	}
+
+	tracing, err := newTracing(ctx, p.version)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to Configure Denvr Tracing", err.Error())
+		return
+	}
+
+	var auditLog *auditLogger
+	if file := data.AuditLogFile.ValueString(); file != "" {
+		auditLog, err = newAuditLogger(file, creds.Username)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("audit_log_file"),
+				"Unable to Open Denvr Audit Log",
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	policy, diags := data.guardrailPolicy(ctx)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	opts := denvrClientOptions{
+		AuditLog:              auditLog,
+		Policy:                policy,
+		ReadOnly:              data.ReadOnly.ValueBool(),
+		StrictCapacityCheck:   data.StrictCapacityCheck.ValueBool(),
+		Tracing:               tracing,
+		Transport:             transport,
+		MaxRetries:            defaultMaxRetries,
+		RetryMaxWait:          defaultRetryMaxWait,
+		MaxConcurrentRequests: int(data.MaxConcurrentRequests.ValueInt64()),
+		RequestsPerSecond:     data.RequestsPerSecond.ValueFloat64(),
+	}
+	if !data.MaxRetries.IsNull() {
+		opts.MaxRetries = int(data.MaxRetries.ValueInt64())
+	} else if retries, ok := denvrConfigRetries(configPath); ok {
+		opts.MaxRetries = retries
+	}
+	if !data.RetryMaxWait.IsNull() {
+		opts.RetryMaxWait = time.Duration(data.RetryMaxWait.ValueInt64()) * time.Second
+	}
+
+	client := newDenvrClient(creds, opts)
+
+	// Authenticate once up front so bad credentials or a wrong server are reported against the
+	// provider rather than as a low-level HTTP error from the first resource. The token is reused.
+	if !data.SkipCredentialsValidation.ValueBool() {
+		tflog.Debug(ctx, "Validating Denvr credentials")
+		if _, err := client.auth.accessToken(ctx); err != nil {
+			summary, detail := describeAuthError(creds.Server, err)
+			resp.Diagnostics.AddError(summary, detail)
+			return
+		}
+	}
+
+	resp.DataSourceData = client
+	resp.ResourceData = client
+}
 
+// guardrailPolicy returns the policy set by the allowed_* and max_gpus_per_resource attributes.
+func (m denvrProviderModel) guardrailPolicy(ctx context.Context) (*guardrailPolicy, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	policy := &guardrailPolicy{MaxGPUsPerResource: int(m.MaxGPUsPerResource.ValueInt64())}
+
+	for _, allowed := range []struct {
+		set    types.Set
+		target *[]string
+	}{
+		{m.AllowedClusters, &policy.AllowedClusters},
+		{m.AllowedConfigurations, &policy.AllowedConfigurations},
+		{m.AllowedHardwarePackages, &policy.AllowedHardwarePackages},
+		{m.AllowedResourcePools, &policy.AllowedResourcePools},
+	} {
+		if !allowed.set.IsNull() {
+			diags.Append(allowed.set.ElementsAs(ctx, allowed.target, false)...)
+		}
+	}
+	return policy, diags
+}
+
+// credentialSources returns the credential chain in order of precedence. A profile set on the
+// provider block picks the tenant for that provider, e.g. one of several aliases, so it's read
+// before the environment variables, while DENVR_PROFILE keeps the config file last.
+func (m denvrProviderModel) credentialSources(configPath string) []credentialSource {
+	static := staticCredentials(denvrCredentials{
+		Server:   m.Server.ValueString(),
+		Username: m.Username.ValueString(),
+		Password: m.Password.ValueString(),
+	})
+	process := processCredentials(m.CredentialProcess.ValueString())
+
+	if profile := m.Profile.ValueString(); profile != "" {
+		return []credentialSource{static, fileCredentials(configPath, profile), envCredentials(), process}
+	}
+	return []credentialSource{static, envCredentials(), process, fileCredentials(configPath, denvrProfile(m.Profile.ValueString()))}
+}
+
+// unknownAttributes returns the names of the provider attributes which aren't known yet.
+func (m denvrProviderModel) unknownAttributes() []string {
+	var unknown []string
+	for name, value := range map[string]attr.Value{
+		"allowed_clusters":            m.AllowedClusters,
+		"allowed_configurations":      m.AllowedConfigurations,
+		"allowed_hardware_packages":   m.AllowedHardwarePackages,
+		"allowed_resource_pools":      m.AllowedResourcePools,
+		"audit_log_file":              m.AuditLogFile,
+		"ca_bundle_file":              m.CABundleFile,
+		"client_certificate":          m.ClientCertificate,
+		"client_key":                  m.ClientKey,
+		"credential_process":          m.CredentialProcess,
+		"http_proxy":                  m.HTTPProxy,
+		"insecure_skip_verify":        m.InsecureSkipVerify,
+		"max_concurrent_requests":     m.MaxConcurrentRequests,
+		"max_gpus_per_resource":       m.MaxGPUsPerResource,
+		"max_retries":                 m.MaxRetries,
+		"password":                    m.Password,
+		"profile":                     m.Profile,
+		"read_only":                   m.ReadOnly,
+		"requests_per_second":         m.RequestsPerSecond,
+		"retry_max_wait":              m.RetryMaxWait,
+		"server":                      m.Server,
+		"skip_credentials_validation": m.SkipCredentialsValidation,
+		"strict_capacity_check":       m.StrictCapacityCheck,
+		"username":                    m.Username,
+	} {
+		if value.IsUnknown() {
+			unknown = append(unknown, name)
+		}
+	}
+	slices.Sort(unknown)
+	return unknown
 }
 
 func (p *denvrProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
@@ -17,5 +17,5 @@ provider "denvr" {
 // acceptance testing. The factory function will be invoked for every Terraform
 // CLI command executed to create a new provider server instance.
 var testAccProtoV6ProviderFactories = map[string]func() (tfprotov6.ProviderServer, error){
-	"denvr": providerserver.NewProtocol6WithError(New()()),
+	"denvr": providerserver.NewProtocol6WithError(New("test")()),
 }
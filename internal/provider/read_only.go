@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+// errReadOnly is returned for requests which could change resources while the provider is read_only.
+var errReadOnly = errors.New("the denvr provider is read_only")
+
+// checkReadOnly adds an error and returns true if the provider is read_only, for resource
+// operations which would change infrastructure.
+func (c *denvrClient) checkReadOnly(operation string, diags *diag.Diagnostics) bool {
+	if c == nil || !c.readOnly {
+		return false
+	}
+
+	diags.AddError(
+		"Denvr Provider Is Read-Only",
+		fmt.Sprintf("%s is not allowed because the provider has read_only = true. ", operation)+
+			"Plans, refreshes and imports still work, remove read_only to make changes.",
+	)
+	return true
+}
+
+// readOnlyTransport rejects every request other than reads and the authentication exchange,
+// so nothing can be changed even by code paths which don't call checkReadOnly.
+type readOnlyTransport struct {
+	base http.RoundTripper
+
+	// authPath is the path of the authentication endpoint under the server, which may have a path
+	// of its own, e.g. behind a proxy at https://host/denvr
+	authPath string
+}
+
+func newReadOnlyTransport(base http.RoundTripper, server string) *readOnlyTransport {
+	authPath := denvrAuthPath
+	if u, err := url.Parse(server); err == nil {
+		authPath = strings.TrimSuffix(u.Path, "/") + denvrAuthPath
+	}
+	return &readOnlyTransport{base: base, authPath: authPath}
+}
+
+func (t *readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch {
+	case req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions:
+	case req.Method == http.MethodPost && req.URL.Path == t.authPath:
+	default:
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("%w, refusing %s %s", errReadOnly, req.Method, req.URL.Redacted())
+	}
+	return t.base.RoundTrip(req)
+}
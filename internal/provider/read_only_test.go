@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+func TestReadOnlyTransport(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	client := newDenvrClient(denvrCredentials{Server: server.URL}, denvrClientOptions{ReadOnly: true, MaxRetries: 3})
+	proxied := newDenvrClient(denvrCredentials{Server: server.URL + "/denvr"}, denvrClientOptions{ReadOnly: true, MaxRetries: 3})
+
+	cases := []struct {
+		client  *denvrClient
+		method  string
+		path    string
+		allowed bool
+	}{
+		{client, http.MethodGet, "/api/v1/servers/virtual/GetServer", true},
+		{client, http.MethodPost, denvrAuthPath, true},
+		{client, http.MethodPost, "/api/v1/servers/virtual/CreateServer", false},
+		{client, http.MethodDelete, "/api/v1/servers/virtual/DestroyServer", false},
+		{client, http.MethodPut, "/api/v1/servers/applications/StartApplication", false},
+		{proxied, http.MethodPost, "/denvr" + denvrAuthPath, true},
+		{proxied, http.MethodPost, "/denvr/api/v1/servers/virtual/CreateServer", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.method+" "+c.path, func(t *testing.T) {
+			before := requests.Load()
+			req, _ := http.NewRequest(c.method, server.URL+c.path, strings.NewReader("{}"))
+			resp, err := c.client.httpClient.Do(req)
+			if resp != nil {
+				resp.Body.Close()
+			}
+
+			sent := requests.Load() - before
+			if c.allowed && (err != nil || sent != 1) {
+				t.Errorf("expected the request to be sent once, got %d requests and %v", sent, err)
+			} else if !c.allowed && (!errors.Is(err, errReadOnly) || sent != 0) {
+				t.Errorf("expected the request to be refused before it is sent, got %d requests and %v", sent, err)
+			}
+		})
+	}
+}
+
+func TestCheckReadOnly(t *testing.T) {
+	cases := []struct {
+		name   string
+		client *denvrClient
+		want   bool
+	}{
+		{"read only", &denvrClient{readOnly: true}, true},
+		{"read write", &denvrClient{}, false},
+		{"unconfigured", nil, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var diags diag.Diagnostics
+			if got := c.client.checkReadOnly("Creating a VM", &diags); got != c.want || diags.HasError() != c.want {
+				t.Errorf("expected %t, got %t with %v", c.want, got, diags)
+			}
+		})
+	}
+}
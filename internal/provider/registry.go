@@ -0,0 +1,241 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// manifestMediaTypes are the manifest formats we accept when resolving a tag to a digest.
+// Index/list types come first so multi-arch images resolve to the digest of the index.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+// registryClient makes requests to the OCI distribution API of a container registry.
+type registryClient struct {
+	httpClient *http.Client
+	username   string
+	password   string
+}
+
+// newRegistryClient returns a registry client using transport, or http.DefaultTransport if nil.
+func newRegistryClient(transport http.RoundTripper, username string, password string) *registryClient {
+	return &registryClient{
+		httpClient: &http.Client{Timeout: 30 * time.Second, Transport: transport},
+		username:   username,
+		password:   password,
+	}
+}
+
+// registryBaseURL returns the API endpoint for a registry domain.
+// Docker Hub is served from registry-1.docker.io and local registries are assumed to use plain http.
+func registryBaseURL(domain string) string {
+	if domain == dockerHubRegistry {
+		return "https://registry-1.docker.io"
+	}
+
+	host := domain
+	if h, _, err := net.SplitHostPort(domain); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return "http://" + domain
+	}
+	return "https://" + domain
+}
+
+// resolveDigest returns the content digest of the manifest an image reference points to.
+// References which already include a digest are returned as-is.
+func (c *registryClient) resolveDigest(ctx context.Context, ref imageReference) (string, error) {
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+	ctx = withAPILogSecrets(ctx, c.username, c.password)
+
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", registryBaseURL(ref.Domain), ref.Repository(), tag)
+	tflog.Debug(ctx, "Resolving image digest from "+manifestURL)
+
+	resp, err := c.do(ctx, http.MethodHead, manifestURL)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	// Not every registry returns the digest header on HEAD requests, so hash the manifest ourselves
+	resp, err = c.do(ctx, http.MethodGet, manifestURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		return "", fmt.Errorf("error reading manifest from %s: %w", manifestURL, err)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// do makes a manifest request, answering any Basic or Bearer authentication challenge from the registry.
+func (c *registryClient) do(ctx context.Context, method string, manifestURL string) (*http.Response, error) {
+	resp, err := c.request(ctx, method, manifestURL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+
+		authorization, err := c.authorize(ctx, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = c.request(ctx, method, manifestURL, authorization)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("registry returned %s for %s", resp.Status, manifestURL)
+	}
+	return resp, nil
+}
+
+func (c *registryClient) request(ctx context.Context, method string, manifestURL string, authorization string) (*http.Response, error) {
+	ctx = withAPILogSecrets(ctx, authorization)
+	req, err := http.NewRequestWithContext(ctx, method, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting %s: %w", manifestURL, err)
+	}
+	return resp, nil
+}
+
+// authorize returns an Authorization header value for a WWW-Authenticate challenge.
+func (c *registryClient) authorize(ctx context.Context, challenge string) (string, error) {
+	scheme, params := parseAuthChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.username == "" && c.password == "" {
+			return "", fmt.Errorf("registry requires credentials but none were configured")
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(c.username, c.password)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+		token, err := c.token(ctx, params)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	default:
+		return "", fmt.Errorf("unsupported registry authentication challenge %q", challenge)
+	}
+}
+
+// token fetches a bearer token from the registry's token service.
+func (c *registryClient) token(ctx context.Context, params map[string]string) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid registry token realm %q", params["realm"])
+	}
+
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if value, ok := params[key]; ok {
+			query.Set(key, value)
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting registry token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry token service returned %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("error parsing registry token response: %w", err)
+	}
+
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// parseAuthChallenge splits a challenge like `Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`
+// into the scheme and its parameters.
+func parseAuthChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+
+	for rest != "" {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		if key != "" {
+			params[strings.ToLower(strings.TrimSpace(key))] = value
+		}
+	}
+
+	return scheme, params
+}
@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testManifest = `{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.manifest.v1+json"}`
+
+// newTestRegistry starts a registry stub serving a single manifest behind bearer token authentication.
+func newTestRegistry(t *testing.T, digestHeader bool) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/token", func(resp http.ResponseWriter, req *http.Request) {
+		username, password, ok := req.BasicAuth()
+		if !ok || username != "denvr" || password != "hunter2" {
+			resp.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if req.URL.Query().Get("scope") != "repository:org/img:pull" {
+			resp.WriteHeader(http.StatusForbidden)
+			return
+		}
+		resp.Write([]byte(`{"token": "registry-token"}`))
+	})
+	mux.HandleFunc("/v2/org/img/manifests/v1", func(resp http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer registry-token" {
+			resp.Header().Set(
+				"WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:org/img:pull"`, server.URL),
+			)
+			resp.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.Contains(req.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json") {
+			resp.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		if digestHeader {
+			resp.Header().Set("Docker-Content-Digest", "sha256:"+strings.Repeat("a", 64))
+		}
+		if req.Method == http.MethodGet {
+			resp.Write([]byte(testManifest))
+		}
+	})
+
+	return server
+}
+
+func TestRegistryResolveDigest(t *testing.T) {
+	server := newTestRegistry(t, true)
+	ref, err := parseImageReference(strings.TrimPrefix(server.URL, "http://") + "/org/img:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := newRegistryClient(nil, "denvr", "hunter2").resolveDigest(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != "sha256:"+strings.Repeat("a", 64) {
+		t.Errorf("unexpected digest %q", digest)
+	}
+
+	if _, err := newRegistryClient(nil, "denvr", "wrong").resolveDigest(context.Background(), ref); err == nil {
+		t.Error("expected an error with invalid credentials")
+	}
+
+	ref.Tag = "v2"
+	if _, err := newRegistryClient(nil, "denvr", "hunter2").resolveDigest(context.Background(), ref); err == nil {
+		t.Error("expected an error for a missing tag")
+	}
+}
+
+func TestRegistryResolveDigest_hashManifest(t *testing.T) {
+	server := newTestRegistry(t, false)
+	ref, err := parseImageReference(strings.TrimPrefix(server.URL, "http://") + "/org/img:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := newRegistryClient(nil, "denvr", "hunter2").resolveDigest(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := sha256.Sum256([]byte(testManifest))
+	if expected := "sha256:" + hex.EncodeToString(hash[:]); digest != expected {
+		t.Errorf("digest = %q, expected %q", digest, expected)
+	}
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	scheme, params := parseAuthChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`)
+	if scheme != "Bearer" {
+		t.Errorf("scheme = %q, expected Bearer", scheme)
+	}
+
+	expected := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull",
+	}
+	for key, value := range expected {
+		if params[key] != value {
+			t.Errorf("params[%q] = %q, expected %q", key, params[key], value)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRetryTransport returns a retry transport which records its waits instead of sleeping.
+func newTestRetryTransport(maxRetries int, maxWait time.Duration) (*retryTransport, *[]time.Duration) {
+	var waits []time.Duration
+	transport := newRetryTransport(http.DefaultTransport, maxRetries, maxWait)
+	transport.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return transport, &waits
+}
+
+func TestRetryTransport(t *testing.T) {
+	cases := []struct {
+		name     string
+		method   string
+		statuses []int
+		want     int
+		attempts int
+	}{
+		{"success", http.MethodGet, []int{200}, 200, 1},
+		{"get 503", http.MethodGet, []int{503, 502, 200}, 200, 3},
+		{"delete 500", http.MethodDelete, []int{500, 200}, 200, 2},
+		{"post 429", http.MethodPost, []int{429, 200}, 200, 2},
+		{"post 503", http.MethodPost, []int{503, 200}, 503, 1},
+		{"not implemented", http.MethodGet, []int{501, 200}, 501, 1},
+		{"client error", http.MethodGet, []int{404, 200}, 404, 1},
+		{"exhausted", http.MethodGet, []int{503, 503, 503, 503, 200}, 503, 3},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			attempts := 0
+			var bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				body, _ := io.ReadAll(req.Body)
+				bodies = append(bodies, string(body))
+				resp.WriteHeader(c.statuses[attempts])
+				attempts++
+			}))
+			defer server.Close()
+
+			transport, _ := newTestRetryTransport(2, time.Second)
+			req, _ := http.NewRequest(c.method, server.URL, strings.NewReader(`{"name": "vm"}`))
+			resp, err := (&http.Client{Transport: transport}).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != c.want {
+				t.Errorf("expected status %d, got %d", c.want, resp.StatusCode)
+			}
+			if attempts != c.attempts {
+				t.Errorf("expected %d attempts, got %d", c.attempts, attempts)
+			}
+			for _, body := range bodies {
+				if body != `{"name": "vm"}` {
+					t.Errorf("expected the request body to be replayed, got %q", body)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryTransport_connectionError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	// Connection failures are retried for every method because the request never reached the API
+	transport, waits := newTestRetryTransport(3, time.Second)
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("{}"))
+	if _, err := (&http.Client{Transport: transport}).Do(req); err == nil {
+		t.Fatal("expected a connection error")
+	}
+	if len(*waits) != 3 {
+		t.Errorf("expected 3 retries, got %d", len(*waits))
+	}
+}
+
+func TestRetryTransport_retryAfter(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		attempts++
+		switch attempts {
+		case 1:
+			resp.Header().Set("Retry-After", "7")
+			resp.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			resp.Header().Set("Retry-After", "120")
+			resp.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	transport, waits := newTestRetryTransport(5, 30*time.Second)
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(*waits) != 2 || (*waits)[0] != 7*time.Second || (*waits)[1] != 30*time.Second {
+		t.Errorf("expected waits of 7s and 30s (capped), got %v", *waits)
+	}
+}
+
+func TestRetryTransport_backoff(t *testing.T) {
+	transport := newRetryTransport(http.DefaultTransport, 10, 10*time.Second)
+
+	for attempt, ceiling := range []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		for i := 0; i < 100; i++ {
+			if wait := transport.backoff(attempt, nil); wait < 0 || wait > ceiling {
+				t.Fatalf("attempt %d: expected a wait between 0 and %s, got %s", attempt, ceiling, wait)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if wait, ok := parseRetryAfter("5"); !ok || wait != 5*time.Second {
+		t.Errorf("expected 5s, got %s", wait)
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if wait, ok := parseRetryAfter(date); !ok || wait < 55*time.Second || wait > time.Minute {
+		t.Errorf("expected about a minute, got %s", wait)
+	}
+
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Error("expected an invalid Retry-After to be ignored")
+	}
+}
@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const tracerName = "terraform-provider-denvr"
+
+// Span attributes describing the Denvr resource an operation acts on
+const (
+	attrCluster       = attribute.Key("denvr.cluster")
+	attrConfiguration = attribute.Key("denvr.configuration")
+	attrResourceID    = attribute.Key("denvr.resource_id")
+	attrStatus        = attribute.Key("denvr.status")
+	attrPollAttempt   = attribute.Key("denvr.poll.attempt")
+)
+
+// tracingFlushTimeout bounds how long a resource operation waits for its spans to be exported.
+const tracingFlushTimeout = 5 * time.Second
+
+// denvrTracing holds the tracer for provider operations and API calls.
+type denvrTracing struct {
+	tracer trace.Tracer
+
+	// flush exports any buffered spans
+	flush func(context.Context) error
+}
+
+// disabledTracing returns a tracer which records nothing.
+func disabledTracing() *denvrTracing {
+	return &denvrTracing{
+		tracer: noop.NewTracerProvider().Tracer(tracerName),
+		flush:  func(context.Context) error { return nil },
+	}
+}
+
+// newTracing sets up an OTLP/HTTP trace exporter when OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set, and disables tracing otherwise. The exporter is
+// configured by the standard OTEL_EXPORTER_OTLP_* environment variables, e.g. headers and timeouts.
+func newTracing(ctx context.Context, version string) (*denvrTracing, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return disabledTracing(), nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := sdkresource.Merge(
+		sdkresource.Default(),
+		sdkresource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(tracerName), semconv.ServiceVersion(version)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	return &denvrTracing{
+		tracer: provider.Tracer(tracerName, trace.WithInstrumentationVersion(version)),
+		flush:  provider.ForceFlush,
+	}, nil
+}
+
+// startSpan starts a span for a resource operation or one of its steps, like a wait-loop poll.
+func (c *denvrClient) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return c.tracing.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endOperation ends the span of a resource CRUD call with the errors in its diagnostics and exports
+// the trace straight away, as Terraform may stop the provider process as soon as the call returns.
+func (c *denvrClient) endOperation(ctx context.Context, span trace.Span, diags diag.Diagnostics) {
+	if diags.HasError() {
+		errs := diags.Errors()
+		for _, d := range errs {
+			span.RecordError(errors.New(d.Summary() + ": " + d.Detail()))
+		}
+		span.SetStatus(codes.Error, errs[0].Summary())
+	}
+	span.End()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tracingFlushTimeout)
+	defer cancel()
+	if err := c.tracing.flush(ctx); err != nil {
+		tflog.Warn(ctx, "Unable to export traces: "+err.Error())
+	}
+}
+
+// endSpan ends a span, marking it as failed if err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracingTransport records a client span for every HTTP request to the Denvr API.
+type tracingTransport struct {
+	base   http.RoundTripper
+	tracer trace.Tracer
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.Redacted()),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			resp.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	client := newDenvrClient(denvrCredentials{Server: server.URL}, denvrClientOptions{
+		Tracing: &denvrTracing{tracer: provider.Tracer(tracerName), flush: provider.ForceFlush},
+	})
+
+	ctx, span := client.startSpan(context.Background(), "denvr_vm.Create", attrCluster.String("Msc1"))
+	for _, path := range []string{"/ok", "/missing"} {
+		pollCtx, poll := client.startSpan(ctx, "denvr_vm.poll")
+		req, _ := http.NewRequestWithContext(pollCtx, http.MethodGet, server.URL+path, nil)
+		resp, err := client.httpClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		endSpan(poll, nil)
+	}
+
+	var diags diag.Diagnostics
+	diags.AddError("Create server failed", "quota exceeded")
+	client.endOperation(ctx, span, diags)
+
+	spans := map[string][]tracetest.SpanStub{}
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name] = append(spans[s.Name], s)
+	}
+	if len(spans["denvr_vm.Create"]) != 1 || len(spans["denvr_vm.poll"]) != 2 || len(spans["HTTP GET"]) != 2 {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+
+	op := spans["denvr_vm.Create"][0]
+	if op.Status.Code != codes.Error || op.Status.Description != "Create server failed" {
+		t.Errorf("expected the operation span to record the error, got %v", op.Status)
+	}
+	for i, s := range spans["denvr_vm.poll"] {
+		if s.Parent.SpanID() != op.SpanContext.SpanID() {
+			t.Errorf("expected poll %d to be a child of the operation span", i)
+		}
+		if spans["HTTP GET"][i].Parent.SpanID() != s.SpanContext.SpanID() {
+			t.Errorf("expected HTTP request %d to be a child of its poll span", i)
+		}
+	}
+	if status := spans["HTTP GET"][1].Status; status.Code != codes.Error {
+		t.Errorf("expected the 404 response to mark its span as failed, got %v", status)
+	}
+}
+
+func TestNewTracing(t *testing.T) {
+	var mu sync.Mutex
+	var exported []byte
+	collector := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if req.URL.Path == "/v1/traces" {
+			exported = append(exported, body...)
+		}
+	}))
+	defer collector.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	tracing, err := newTracing(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, span := tracing.tracer.Start(context.Background(), "disabled"); span.IsRecording() {
+		t.Error("expected tracing to be disabled without an OTLP endpoint")
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+	tracing, err = newTracing(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := newDenvrClient(denvrCredentials{Server: collector.URL}, denvrClientOptions{Tracing: tracing})
+	ctx, span := client.startSpan(context.Background(), "denvr_app.Delete", attrResourceID.String("my-jupyter"))
+	client.endOperation(ctx, span, nil)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, want := range []string{"denvr_app.Delete", "my-jupyter", tracerName} {
+		if !bytes.Contains(exported, []byte(want)) {
+			t.Errorf("expected the exported trace to contain %q", want)
+		}
+	}
+}
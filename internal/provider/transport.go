@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// transportOptions configure how the provider connects to the Denvr API and container registries.
+type transportOptions struct {
+	// HTTPProxy overrides the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	HTTPProxy string
+
+	// CABundleFile is a PEM file of certificates trusted in addition to the system roots
+	CABundleFile string
+
+	// ClientCertificate and ClientKey are PEM content or paths to PEM files used for mutual TLS
+	ClientCertificate string
+	ClientKey         string
+
+	InsecureSkipVerify bool
+	UserAgent          string
+}
+
+// newTransport returns an http.Transport configured with the proxy and TLS options, wrapped to set the User-Agent.
+func newTransport(opts transportOptions) (http.RoundTripper, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.HTTPProxy != "" {
+		proxyURL, err := url.Parse(opts.HTTPProxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid http_proxy %q: expected a URL like http://proxy.example.com:3128", opts.HTTPProxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	} else {
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.CABundleFile != "" {
+		bundle, err := os.ReadFile(opts.CABundleFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ca_bundle_file: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("ca_bundle_file %q does not contain any PEM encoded certificates", opts.CABundleFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.ClientCertificate != "" || opts.ClientKey != "" {
+		if opts.ClientCertificate == "" || opts.ClientKey == "" {
+			return nil, fmt.Errorf("client_certificate and client_key must be set together")
+		}
+
+		certPEM, err := readPEM(opts.ClientCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client_certificate: %w", err)
+		}
+		keyPEM, err := readPEM(opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client_key: %w", err)
+		}
+
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client_certificate or client_key: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	return &userAgentTransport{base: transport, userAgent: opts.UserAgent}, nil
+}
+
+// readPEM returns PEM content given either inline or as the path of a file.
+func readPEM(value string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return os.ReadFile(value)
+}
+
+// userAgent identifies the provider and Terraform versions to Denvr support,
+// e.g. "terraform-provider-denvr/1.2.0 terraform/1.11.0".
+func userAgent(providerVersion string, terraformVersion string) string {
+	if terraformVersion == "" {
+		terraformVersion = "unknown"
+	}
+	return fmt.Sprintf("terraform-provider-denvr/%s terraform/%s", providerVersion, terraformVersion)
+}
+
+// userAgentTransport sets the User-Agent header on every request.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.userAgent == "" {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeServerCA writes the certificate of a httptest TLS server to a PEM file.
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewTransport_userAgent(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		got = req.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	transport, err := newTransport(transportOptions{UserAgent: userAgent("1.2.3", "1.11.0")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got != "terraform-provider-denvr/1.2.3 terraform/1.11.0" {
+		t.Errorf("unexpected User-Agent %q", got)
+	}
+}
+
+func TestNewTransport_proxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		proxied = req.URL.String()
+	}))
+	defer proxy.Close()
+
+	transport, err := newTransport(transportOptions{HTTPProxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Get("http://api.denvr.test/api/v1/servers/virtual/GetServers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if proxied != "http://api.denvr.test/api/v1/servers/virtual/GetServers" {
+		t.Errorf("expected the request to go through the proxy, got %q", proxied)
+	}
+
+	if _, err := newTransport(transportOptions{HTTPProxy: "proxy.example.com"}); err == nil {
+		t.Error("expected an invalid proxy URL to fail")
+	}
+}
+
+func TestNewTransport_tls(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	cases := []struct {
+		name    string
+		opts    transportOptions
+		wantErr string
+	}{
+		{"untrusted", transportOptions{}, "certificate"},
+		{"ca bundle", transportOptions{CABundleFile: writeServerCA(t, server)}, ""},
+		{"insecure", transportOptions{InsecureSkipVerify: true}, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			transport, err := newTransport(c.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+			if c.wantErr == "" && err != nil {
+				t.Fatal(err)
+			} else if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+				t.Fatalf("expected an error containing %q, got %v", c.wantErr, err)
+			}
+			if resp != nil {
+				resp.Body.Close()
+			}
+		})
+	}
+}
+
+func TestNewTransport_clientCertificate(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if len(req.TLS.PeerCertificates) == 0 {
+			resp.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	// Reuse the server's own key pair as the client certificate
+	key, err := x509.MarshalPKCS8PrivateKey(server.TLS.Certificates[0].PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	keyPath := filepath.Join(t.TempDir(), "client.key")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	transport, err := newTransport(transportOptions{
+		CABundleFile:      writeServerCA(t, server),
+		ClientCertificate: certPEM,
+		ClientKey:         keyPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the client certificate to be accepted, got %s", resp.Status)
+	}
+
+	if _, err := newTransport(transportOptions{ClientCertificate: certPEM}); err == nil {
+		t.Error("expected client_certificate without client_key to fail")
+	}
+}
@@ -2,31 +2,41 @@ package provider
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/denvrdata/go-denvr/api/v1/servers/virtual"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/resourcevalidator"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64default"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 var (
-	_ resource.Resource = &vmResource{}
+	_ resource.Resource                     = &vmResource{}
+	_ resource.ResourceWithConfigure        = &vmResource{}
+	_ resource.ResourceWithConfigValidators = &vmResource{}
+	_ resource.ResourceWithModifyPlan       = &vmResource{}
 )
 
-type vmResource struct{}
+type vmResource struct {
+	client *denvrClient
+}
 
 type vmResourceModel struct {
+	AdoptExisting                  types.Bool   `tfsdk:"adopt_existing"`
 	Cluster                        types.String `tfsdk:"cluster"`
 	Configuration                  types.String `tfsdk:"configuration"`
+	DeletionProtection             types.Bool   `tfsdk:"deletion_protection"`
 	DirectAttachedStoragePersisted types.Bool   `tfsdk:"direct_attached_storage_persisted"`
 	DirectStorageMountPath         types.String `tfsdk:"direct_storage_mount_path"`
 	GpuType                        types.String `tfsdk:"gpu_type"`
@@ -40,6 +50,7 @@ type vmResourceModel struct {
 	OperatingSystemImage           types.String `tfsdk:"operating_system_image"`
 	PersistStorage                 types.Bool   `tfsdk:"persist_storage"`
 	PersonalStorageMountPath       types.String `tfsdk:"personal_storage_mount_path"`
+	PlacementPreferences           types.List   `tfsdk:"placement_preferences"`
 	PrivateIp                      types.String `tfsdk:"private_ip"`
 	RootDiskSize                   types.Int32  `tfsdk:"root_disk_size"`
 	Rpool                          types.String `tfsdk:"rpool"`
@@ -53,6 +64,7 @@ type vmResourceModel struct {
 	Vcpus                          types.Int32  `tfsdk:"vcpus"`
 	Vpc                            types.String `tfsdk:"vpc"`
 	Wait                           types.Bool   `tfsdk:"wait"`
+	WaitForCapacityTimeout         types.String `tfsdk:"wait_for_capacity_timeout"`
 	Interval                       types.Int64  `tfsdk:"interval"`
 	Timeout                        types.Int64  `tfsdk:"timeout"`
 }
@@ -65,15 +77,26 @@ func (r *vmResource) Metadata(ctx context.Context, req resource.MetadataRequest,
 	resp.TypeName = req.ProviderTypeName + "_vm"
 }
 
+func (r *vmResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = configureClient(req, resp)
+}
+
 func (r *vmResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
+			"adopt_existing": adoptExistingAttribute("VM",
+				"The Denvr API doesn't return the root disk size of a VM, so it's adopted whatever its `root_disk_size` with a warning."),
 			"cluster": schema.StringAttribute{
-				Required: true,
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: "The cluster to create the VM in. Required unless `placement_preferences` is set.",
 			},
 			"configuration": schema.StringAttribute{
-				Required: true,
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: "The VM configuration, e.g. `A100_40GB_SXM_1x`. Required unless `placement_preferences` is set.",
 			},
+			"deletion_protection": deletionProtectionAttribute("VM"),
 			"direct_attached_storage_persisted": schema.BoolAttribute{
 				Optional: true,
 				Computed: true,
@@ -120,6 +143,7 @@ func (r *vmResource) Schema(ctx context.Context, req resource.SchemaRequest, res
 				Computed: true,
 				Default:  stringdefault.StaticString("/home/ubuntu/personal"),
 			},
+			"placement_preferences": placementPreferencesAttribute(),
 			"private_ip": schema.StringAttribute{
 				Computed: true,
 			},
@@ -127,7 +151,9 @@ func (r *vmResource) Schema(ctx context.Context, req resource.SchemaRequest, res
 				Required: true,
 			},
 			"rpool": schema.StringAttribute{
-				Required: true,
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: "The resource pool to create the VM in. Required unless `placement_preferences` is set.",
 			},
 			"ssh_keys": schema.ListAttribute{
 				Required:    true,
@@ -164,10 +190,14 @@ func (r *vmResource) Schema(ctx context.Context, req resource.SchemaRequest, res
 				Computed: true,
 				Default:  booldefault.StaticBool(false),
 			},
+			"wait_for_capacity_timeout": waitForCapacityTimeoutAttribute("VM"),
 			"interval": schema.Int64Attribute{
 				Optional: true,
 				Computed: true,
 				Default:  int64default.StaticInt64(30),
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
 			},
 			"timeout": schema.Int64Attribute{
 				Optional: true,
@@ -178,7 +208,62 @@ func (r *vmResource) Schema(ctx context.Context, req resource.SchemaRequest, res
 	}
 }
 
+func (r *vmResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		resourcevalidator.ExactlyOneOf(path.MatchRoot("configuration"), path.MatchRoot("placement_preferences")),
+		resourcevalidator.RequiredTogether(path.MatchRoot("cluster"), path.MatchRoot("configuration"), path.MatchRoot("rpool")),
+	}
+}
+
+func (r *vmResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	defer checkReplaceProtection(ctx, "VM", req, resp)
+
+	// Nothing to plan when destroying
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan vmResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	var state *vmResourceModel
+	if !req.State.Raw.IsNull() {
+		state = &vmResourceModel{}
+		resp.Diagnostics.Append(req.State.Get(ctx, state)...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	planPlacement(ctx, &plan, state, resp)
+	resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if r.client == nil {
+		resp.Deferred = deferUnconfigured(req.ClientCapabilities.DeferralAllowed, &resp.Diagnostics)
+		return
+	}
+
+	r.client.policy.checkVM(ctx, plan, state, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Only new VMs draw on capacity, and values which aren't known yet, including placements
+	// chosen from placement_preferences, are checked during apply
+	if (state == nil || len(resp.RequiresReplace) > 0) &&
+		!plan.Cluster.IsUnknown() && !plan.Rpool.IsUnknown() && !plan.Configuration.IsUnknown() && !plan.Name.IsUnknown() {
+		pool := capacityPool{Cluster: plan.Cluster.ValueString(), ResourcePool: plan.Rpool.ValueString()}
+		r.client.checkCapacity(ctx, pool, "denvr_vm", plan.Name.ValueString(), gpusOrZero(plan.Configuration.ValueString()), &resp.Diagnostics)
+	}
+}
+
 func (r *vmResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	if r.client.checkReadOnly("Creating a VM", &resp.Diagnostics) {
+		return
+	}
+
 	tflog.Debug(ctx, "Reading Terraform plan data into vmResourceModel")
 	var data vmResourceModel
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
@@ -186,17 +271,20 @@ func (r *vmResource) Create(ctx context.Context, req resource.CreateRequest, res
 		return
 	}
 
+	ctx, span := r.client.startSpan(ctx, "denvr_vm.Create",
+		attrCluster.String(data.Cluster.ValueString()),
+		attrConfiguration.String(data.Configuration.ValueString()),
+	)
+	defer func() { r.client.endOperation(ctx, span, resp.Diagnostics) }()
+
 	tflog.Debug(ctx, "Constructing virtual server request")
 	serverReq := virtual.CreateServerJSONRequestBody{
-		Cluster:                       data.Cluster.ValueString(),
-		Configuration:                 data.Configuration.ValueString(),
 		DirectStorageMountPath:        data.DirectStorageMountPath.ValueStringPointer(),
 		Name:                          data.Name.ValueStringPointer(),
 		OperatingSystemImage:          data.OperatingSystemImage.ValueStringPointer(),
 		PersistStorage:                data.PersistStorage.ValueBoolPointer(),
 		PersonalStorageMountPath:      data.PersonalStorageMountPath.ValueStringPointer(),
 		RootDiskSize:                  data.RootDiskSize.ValueInt32Pointer(),
-		Rpool:                         data.Rpool.ValueStringPointer(),
 		SshKeys:                       []string{},
 		TenantSharedAdditionalStorage: data.TenantSharedAdditionalStorage.ValueStringPointer(),
 		Vpc:                           data.Vpc.ValueString(),
@@ -213,22 +301,85 @@ func (r *vmResource) Create(ctx context.Context, req resource.CreateRequest, res
 	}
 
 	tflog.Debug(ctx, "Constructing virtual machine service client")
-	client := virtual.NewClient()
+	client := r.client.virtual()
 	tflog.Debug(ctx, client.Server)
 
-	tflog.Debug(ctx, "Making virtual machine creation request")
-	server, err := client.CreateServer(ctx, serverReq)
-	if err != nil {
-		resp.Diagnostics.AddError("Create server failed", err.Error())
+	// The VM is created in the first of its placement_preferences with capacity, or else where it's configured
+	placements, diags := data.placementPreferences(ctx)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
+	placementAvailable := func(ctx context.Context, p vmPlacementModel) (bool, error) {
+		available, err := r.client.availability(ctx, p.Cluster.ValueString(), p.Rpool.ValueString())
+		return available[p.Configuration.ValueString()] > 0, err
+	}
+	skipUnavailable := placementAvailable
+	if placements == nil {
+		placements, skipUnavailable = []vmPlacementModel{data.placement()}, nil
+	}
 
-	serverJson, err := json.MarshalIndent(server, "", "\t")
-	if err != nil {
-		resp.Diagnostics.AddError("Error marshaling server response", err.Error())
-		return
+	// Wait for any of the placements to become available when the clusters are out of capacity
+	capacity := func(ctx context.Context) (bool, error) {
+		var err error
+		for _, p := range placements {
+			ok, availErr := placementAvailable(ctx, p)
+			if ok {
+				return true, nil
+			}
+			if availErr != nil {
+				err = availErr
+			}
+		}
+		return false, err
+	}
+
+	// A VM left behind by an interrupted apply is taken into state instead of created again
+	var server *virtual.Server
+	if data.AdoptExisting.ValueBool() {
+		server = r.adoptServer(ctx, client, &data, placements, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	if server == nil {
+		tflog.Debug(ctx, "Making virtual machine creation request")
+		err := waitForCapacity(ctx, capacityWaitTimeout(data.WaitForCapacityTimeout), time.Duration(data.Interval.ValueInt64())*time.Second, capacity, func() error {
+			_, err := placeVM(ctx, placements, skipUnavailable, func(p vmPlacementModel) error {
+				data.Cluster, data.Configuration, data.Rpool = p.Cluster, p.Configuration, p.Rpool
+				serverReq.Cluster = p.Cluster.ValueString()
+				serverReq.Configuration = p.Configuration.ValueString()
+				serverReq.Rpool = p.Rpool.ValueStringPointer()
+
+				var err error
+				server, err = client.CreateServer(ctx, serverReq)
+				audit := auditEntry{
+					Operation:    "CreateServer",
+					ResourceType: "denvr_vm",
+					Resource:     auditResource(p.Cluster, data.Name),
+					Request:      vmAuditRequest(data),
+				}
+				if server != nil {
+					audit.ResponseID, audit.Status = auditString(server.Id), auditString(server.Status)
+				}
+				r.client.audit(audit, err, &resp.Diagnostics)
+				return err
+			})
+			return err
+		})
+		if err != nil {
+			resp.Diagnostics.AddError("Create server failed", err.Error())
+			return
+		}
 	}
-	tflog.Debug(ctx, string(serverJson))
+	span.SetAttributes(
+		attrCluster.String(data.Cluster.ValueString()),
+		attrConfiguration.String(data.Configuration.ValueString()),
+		attrResourceID.String(*server.Id),
+		attrStatus.String(*server.Status),
+	)
+
 	if data.Wait.ValueBool() {
 		tflog.Debug(ctx, "Waiting for virtual machine to be ready")
 		getParams := virtual.GetServerParams{
@@ -238,13 +389,20 @@ func (r *vmResource) Create(ctx context.Context, req resource.CreateRequest, res
 		}
 
 		start := time.Now()
-		for {
+		for attempt := 1; ; attempt++ {
 			if time.Since(start) > (time.Duration(data.Timeout.ValueInt64()) * time.Second) {
 				resp.Diagnostics.AddError("Timeout Error", "Waiting for VM to come \"ONLINE\" timed out")
 				return
 			}
 
-			server, err = client.GetServer(ctx, &getParams)
+			pollCtx, poll := r.client.startSpan(ctx, "denvr_vm.poll", attrPollAttempt.Int(attempt))
+			var err error
+			server, err = client.GetServer(pollCtx, &getParams)
+			if err == nil {
+				poll.SetAttributes(attrStatus.String(*server.Status))
+				span.SetAttributes(attrStatus.String(*server.Status))
+			}
+			endSpan(poll, err)
 			if err != nil {
 				resp.Diagnostics.AddError("Error checking server status", err.Error())
 				return
@@ -259,7 +417,6 @@ func (r *vmResource) Create(ctx context.Context, req resource.CreateRequest, res
 	}
 
 	tflog.Debug(ctx, "Updating virtual machine resource state")
-	//fmt.Println(string(serverJson))
 	data.GpuType = types.StringValue(*server.GpuType)
 	data.Gpus = types.Int32Value(*server.Gpus)
 	data.Id = types.StringValue(*server.Id)
@@ -288,6 +445,11 @@ func (r *vmResource) Create(ctx context.Context, req resource.CreateRequest, res
 }
 
 func (r *vmResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	if r.client == nil {
+		resp.Deferred = deferUnconfigured(req.ClientCapabilities.DeferralAllowed, &resp.Diagnostics)
+		return
+	}
+
 	var data vmResourceModel
 
 	// Read Terraform prior state data into the model
@@ -297,6 +459,13 @@ func (r *vmResource) Read(ctx context.Context, req resource.ReadRequest, resp *r
 		return
 	}
 
+	ctx, span := r.client.startSpan(ctx, "denvr_vm.Read",
+		attrCluster.String(data.Cluster.ValueString()),
+		attrConfiguration.String(data.Configuration.ValueString()),
+		attrResourceID.String(data.Id.ValueString()),
+	)
+	defer func() { r.client.endOperation(ctx, span, resp.Diagnostics) }()
+
 	// Read API call logic
 	getParams := virtual.GetServerParams{
 		Id:        data.Id.ValueString(),
@@ -305,7 +474,7 @@ func (r *vmResource) Read(ctx context.Context, req resource.ReadRequest, resp *r
 	}
 
 	tflog.Debug(ctx, "Constructing virtual machine service client")
-	client := virtual.NewClient()
+	client := r.client.virtual()
 
 	tflog.Debug(ctx, "Making virtual machine get request")
 	server, err := client.GetServer(ctx, &getParams)
@@ -317,9 +486,9 @@ func (r *vmResource) Read(ctx context.Context, req resource.ReadRequest, resp *r
 		}
 		return
 	}
+	span.SetAttributes(attrStatus.String(*server.Status))
 
 	tflog.Debug(ctx, "Updating virtual machine resource state")
-	//fmt.Println(string(serverJson))
 	data.GpuType = types.StringValue(*server.GpuType)
 	data.Gpus = types.Int32Value(*server.Gpus)
 	data.Id = types.StringValue(*server.Id)
@@ -342,12 +511,21 @@ func (r *vmResource) Read(ctx context.Context, req resource.ReadRequest, resp *r
 		data.DirectAttachedStoragePersisted = types.BoolValue(false)
 	}
 
+	// State from older provider versions has no deletion_protection
+	if data.DeletionProtection.IsNull() {
+		data.DeletionProtection = types.BoolValue(false)
+	}
+
 	// Save data into Terraform state
 	tflog.Debug(ctx, "Saving updated virtual machine Terraform state ")
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
 func (r *vmResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	if r.client.checkReadOnly("Updating a VM", &resp.Diagnostics) {
+		return
+	}
+
 	var data vmResourceModel
 
 	// Read Terraform plan data into the model
@@ -364,6 +542,10 @@ func (r *vmResource) Update(ctx context.Context, req resource.UpdateRequest, res
 }
 
 func (r *vmResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	if r.client.checkReadOnly("Deleting a VM", &resp.Diagnostics) {
+		return
+	}
+
 	tflog.Debug(ctx, "Reading Terraform plan data into vmResourceModel")
 	var data vmResourceModel
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
@@ -371,6 +553,17 @@ func (r *vmResource) Delete(ctx context.Context, req resource.DeleteRequest, res
 		return
 	}
 
+	if checkDeleteProtection("VM", data.Id.ValueString(), data.DeletionProtection, &resp.Diagnostics) {
+		return
+	}
+
+	ctx, span := r.client.startSpan(ctx, "denvr_vm.Delete",
+		attrCluster.String(data.Cluster.ValueString()),
+		attrConfiguration.String(data.Configuration.ValueString()),
+		attrResourceID.String(data.Id.ValueString()),
+	)
+	defer func() { r.client.endOperation(ctx, span, resp.Diagnostics) }()
+
 	tflog.Debug(ctx, "Constructing virtual server request")
 	destroyParams := virtual.DestroyServerParams{
 		Id:        data.Id.ValueString(),
@@ -379,10 +572,20 @@ func (r *vmResource) Delete(ctx context.Context, req resource.DeleteRequest, res
 	}
 
 	tflog.Debug(ctx, "Constructing virtual machine service client")
-	client := virtual.NewClient()
+	client := r.client.virtual()
 
 	tflog.Debug(ctx, "Making virtual machine deletion request")
 	server, err := client.DestroyServer(ctx, &destroyParams)
+	audit := auditEntry{
+		Operation:    "DestroyServer",
+		ResourceType: "denvr_vm",
+		Resource:     auditResource(data.Cluster, data.Name),
+		Request:      vmAuditRequest(data),
+	}
+	if server != nil {
+		audit.ResponseID, audit.Status = auditString(server.Id), auditString(server.Status)
+	}
+	r.client.audit(audit, err, &resp.Diagnostics)
 	if err != nil {
 		if strings.Contains(err.Error(), fmt.Sprintf("\"%s\" not found", destroyParams.Id)) {
 			resp.State.RemoveResource(ctx)
@@ -392,11 +595,22 @@ func (r *vmResource) Delete(ctx context.Context, req resource.DeleteRequest, res
 		return
 	}
 
-	tflog.Debug(ctx, "Updating virtual machine resource state")
-	serverJson, err := json.MarshalIndent(server, "", "\t")
-	if err != nil {
-		resp.Diagnostics.AddError("Error marshaling server response", err.Error())
-		return
+	if server != nil && server.Status != nil {
+		span.SetAttributes(attrStatus.String(*server.Status))
+	}
+
+}
+
+// vmAuditRequest summarises a virtual machine for the audit log, leaving out the SSH keys.
+func vmAuditRequest(data vmResourceModel) map[string]interface{} {
+	return map[string]interface{}{
+		"cluster":                data.Cluster.ValueString(),
+		"configuration":          data.Configuration.ValueString(),
+		"id":                     data.Id.ValueString(),
+		"name":                   data.Name.ValueString(),
+		"namespace":              data.Namespace.ValueString(),
+		"operating_system_image": data.OperatingSystemImage.ValueString(),
+		"rpool":                  data.Rpool.ValueString(),
+		"vpc":                    data.Vpc.ValueString(),
 	}
-	tflog.Debug(ctx, string(serverJson))
 }
@@ -9,6 +9,9 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
 )
 
+// version is set by goreleaser
+var version string = "dev"
+
 //go:generate go tool tfplugindocs generate --provider-dir . -provider-name denvr
 
 func main() {
@@ -16,7 +19,7 @@ func main() {
 		Address: "hashicorp.com/denvrdata/denvr",
 	}
 
-	err := providerserver.Serve(context.Background(), provider.New(), opts)
+	err := providerserver.Serve(context.Background(), provider.New(version), opts)
 	if err != nil {
 		log.Fatal(err.Error())
 	}